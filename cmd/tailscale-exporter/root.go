@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -14,35 +16,84 @@ import (
 	"time"
 
 	headscaleCollector "github.com/adinhodovic/tailscale-exporter/collector/headscale"
+	"github.com/adinhodovic/tailscale-exporter/collector/local"
+	"github.com/adinhodovic/tailscale-exporter/collector/machines"
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
 	tailscale "github.com/adinhodovic/tailscale-exporter/collector/tailscale"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2/clientcredentials"
+	"tailscale.com/tsnet"
 
-	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// healthPath answers with 200 while the exporter is serving.
+const healthPath = "/healthz"
+
 var (
 	// Global flags.
-	listenAddress string
-	metricsPath   string
-	readTimeout   time.Duration
-	writeTimeout  time.Duration
+	listenAddress       string
+	metricsPath         string
+	readTimeout         time.Duration
+	writeTimeout        time.Duration
+	sdTargetPort        int
+	sdFile              string
+	sdFileFormat        string
+	sdFileFilter        string
+	sdFileLabels        string
+	sdFileInterval      time.Duration
+	machinesEnabled     bool
+	machinesConcurrency int
+	machinesTimeout     time.Duration
+
+	// Embedded tailnet node
+	tsnetHostname          string
+	tsnetAuthKey           string
+	tsnetStateDir          string
+	tsnetControlURL        string
+	tsnetListenAddress     string
+	tsnetKeepListenAddress bool
 
 	// Tailscale
-	tailscaleTailnet           string
-	tailscaleOauthClientID     string
-	tailscaleOauthClientSecret string
+	tailscaleTailnet                string
+	tailscaleOauthClientID          string
+	tailscaleOauthClientSecret      string
+	tailscaleDERPLatencyMode        string
+	tailscaleContacts               bool
+	tailscaleContactsHashKey        string
+	tailscaleWebhooks               bool
+	tailscaleLogStreaming           bool
+	tailscaleAudit                  bool
+	tailscaleKeysLongLivedThreshold time.Duration
+	tailscaleAuditStateFile         string
+	tailscaleFlowLogsAggregate      string
+	tailscaleDNSProbeQuery          string
+	tailscaleDNSProbeQueryType      string
+	tailscaleDNSProbeTimeout        time.Duration
+	tailscaleLifecycleStateFile     string
+	tailscaleWebhookSecret          string
 
 	// Headscale
-	headscaleAddress  string
-	headscaleAPIKey   string
-	headscaleInsecure bool
+	headscaleAddress            string
+	headscaleAPIKey             string
+	headscaleInsecure           bool
+	headscaleLifecycleStateFile string
+	headscaleAPIKeyFile         string
+	headscaleAPIKeyWarnBefore   time.Duration
+	headscaleAPIKeyRotateBefore time.Duration
+	headscaleAPIKeyLifetime     time.Duration
+
+	// Local tailscaled
+	localSocket       string
+	localProbePeers   string
+	localProbeType    string
+	localProbePort    int
+	localProbeTimeout time.Duration
 )
 
 // rootCmd represents the base command when called without any subcommands.
@@ -75,6 +126,36 @@ func init() {
 		DurationVar(&readTimeout, "read-timeout", 30*time.Second, "HTTP server read timeout. Set to 0 to disable. (can also be set via READ_TIMEOUT environment variable)")
 	rootCmd.PersistentFlags().
 		DurationVar(&writeTimeout, "write-timeout", 2*time.Minute, "HTTP server write timeout. Must exceed the slowest scrape. Set to 0 to disable. (can also be set via WRITE_TIMEOUT environment variable)")
+	rootCmd.PersistentFlags().
+		IntVar(&sdTargetPort, "sd-target-port", sd.DefaultPort, "Port of the service discovery targets served on "+sd.DevicesPath+" and "+sd.NodesPath+", written to --sd-file and scraped in machines mode (can also be set via SD_TARGET_PORT environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&sdFile, "sd-file", "", "Periodically write the devices and nodes listed by the collectors to this file_sd file (can also be set via SD_FILE environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&sdFileFormat, "sd-file-format", sd.FileFormatJSON, "Format of --sd-file: json or yaml (can also be set via SD_FILE_FORMAT environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&sdFileFilter, "sd-file-filter", "", "Filter of --sd-file targets in query parameter form, e.g. online=true&tag=tag:server (can also be set via SD_FILE_FILTER environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&sdFileLabels, "sd-file-labels", "", "Comma separated target labels written to --sd-file, all labels when empty (can also be set via SD_FILE_LABELS environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&sdFileInterval, "sd-file-interval", time.Minute, "Interval at which --sd-file is written (can also be set via SD_FILE_INTERVAL environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&machinesEnabled, "machines", false, "Scrape the client metrics of every online device and expose them on "+machines.Path+" (can also be set via MACHINES environment variable)")
+	rootCmd.PersistentFlags().
+		IntVar(&machinesConcurrency, "machines-concurrency", 10, "Maximum number of devices scraped at once in machines mode (can also be set via MACHINES_CONCURRENCY environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&machinesTimeout, "machines-timeout", 5*time.Second, "Timeout of each device scrape in machines mode (can also be set via MACHINES_TIMEOUT environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tsnetHostname, "tsnet-hostname", "", "Join the tailnet as a node with this hostname and serve only on its tailnet listener (can also be set via TSNET_HOSTNAME environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tsnetAuthKey, "tsnet-auth-key", "", "Auth key of the tsnet node, a login URL is logged when empty (can also be set via TSNET_AUTH_KEY environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tsnetStateDir, "tsnet-state-dir", "", "Directory of the tsnet node state, defaults to a directory in the user config directory (can also be set via TSNET_STATE_DIR environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tsnetControlURL, "tsnet-control-url", "", "Control server of the tsnet node, e.g. a Headscale URL, defaults to Tailscale (can also be set via TSNET_CONTROL_URL environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tsnetListenAddress, "tsnet-listen-address", ":9250", "Tailnet address the tsnet node serves on (can also be set via TSNET_LISTEN_ADDRESS environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&tsnetKeepListenAddress, "tsnet-keep-listen-address", false, "Also serve on --listen-address when the tsnet node is enabled (can also be set via TSNET_KEEP_LISTEN_ADDRESS environment variable)")
 	rootCmd.PersistentFlags().
 		StringVarP(&tailscaleTailnet, "tailscale-tailnet", "t", "", "Tailscale tailnet (can also be set via TAILSCALE_TAILNET environment variable)")
 	rootCmd.PersistentFlags().
@@ -83,12 +164,60 @@ func init() {
 		StringVar(&headscaleAPIKey, "headscale-api-key", "", "Headscale API key (can also be set via HEADSCALE_API_KEY environment variable)")
 	rootCmd.PersistentFlags().
 		BoolVar(&headscaleInsecure, "headscale-insecure", false, "Allow insecure (plaintext) gRPC connection to Headscale (can also be set via HEADSCALE_INSECURE environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&headscaleLifecycleStateFile, "headscale-lifecycle-state-file", "", "File used to persist the nodes seen by the lifecycle collector across restarts (can also be set via HEADSCALE_LIFECYCLE_STATE_FILE environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&headscaleAPIKeyFile, "headscale-api-key-file", "", "File holding the Headscale API key, used instead of --headscale-api-key when it exists and written with rotated keys (can also be set via HEADSCALE_API_KEY_FILE environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&headscaleAPIKeyWarnBefore, "headscale-api-key-warn-before", 14*24*time.Hour, "Log warnings when the Headscale API key of the exporter expires within this duration (can also be set via HEADSCALE_API_KEY_WARN_BEFORE environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&headscaleAPIKeyRotateBefore, "headscale-api-key-rotate-before", 0, "Rotate the Headscale API key when it expires within this duration, requires --headscale-api-key-file. Set to 0 to disable. (can also be set via HEADSCALE_API_KEY_ROTATE_BEFORE environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&headscaleAPIKeyLifetime, "headscale-api-key-lifetime", 90*24*time.Hour, "Lifetime of Headscale API keys created by rotation (can also be set via HEADSCALE_API_KEY_LIFETIME environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&localSocket, "local-socket", "", "Enable the local collectors with the LocalAPI socket of the tailscaled running next to the exporter, e.g. "+local.DefaultSocket+" (can also be set via LOCAL_SOCKET environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&localProbePeers, "local-probe-peers", "", "Probe the peers selected in query parameter form, e.g. tag=tag:server&user=alice@example.com&name=db-*, from the local tailscaled or the tsnet node (can also be set via LOCAL_PROBE_PEERS environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&localProbeType, "local-probe-type", local.ProbeTypeDisco, "Type of the peer probes: disco, tsmp, icmp or tcp (can also be set via LOCAL_PROBE_TYPE environment variable)")
+	rootCmd.PersistentFlags().
+		IntVar(&localProbePort, "local-probe-port", 0, "Port the tcp peer probes connect to (can also be set via LOCAL_PROBE_PORT environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&localProbeTimeout, "local-probe-timeout", 5*time.Second, "Timeout of each peer probe (can also be set via LOCAL_PROBE_TIMEOUT environment variable)")
 
 	// Authentication flags - API Key or OAuth
 	rootCmd.PersistentFlags().
 		StringVar(&tailscaleOauthClientID, "tailscale-oauth-client-id", "", "OAuth client ID (can also be set via TAILSCALE_OAUTH_CLIENT_ID environment variable)")
 	rootCmd.PersistentFlags().
 		StringVar(&tailscaleOauthClientSecret, "tailscale-oauth-client-secret", "", "OAuth client secret (can also be set via TAILSCALE_OAUTH_CLIENT_SECRET environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&tailscaleContacts, "tailscale-contacts", false, "Enable the tailnet contacts metrics, requires the account_settings:read scope (can also be set via TAILSCALE_CONTACTS environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleContactsHashKey, "tailscale-contacts-hash-key", "", "Secret key of the HMAC-SHA256 hash of contact emails, required with --tailscale-contacts (can also be set via TAILSCALE_CONTACTS_HASH_KEY environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&tailscaleWebhooks, "tailscale-webhooks", false, "Enable the webhook endpoint metrics, requires the webhooks:read scope (can also be set via TAILSCALE_WEBHOOKS environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&tailscaleLogStreaming, "tailscale-log-streaming", false, "Enable the log streaming metrics, requires the log_streaming:read scope (can also be set via TAILSCALE_LOG_STREAMING environment variable)")
+	rootCmd.PersistentFlags().
+		BoolVar(&tailscaleAudit, "tailscale-audit", false, "Enable the configuration audit log counters, requires the logs:configuration:read scope (can also be set via TAILSCALE_AUDIT environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&tailscaleKeysLongLivedThreshold, "tailscale-keys-long-lived-threshold", 30*24*time.Hour, "Lifetime above which reusable auth keys are counted as long-lived (can also be set via TAILSCALE_KEYS_LONG_LIVED_THRESHOLD environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleAuditStateFile, "tailscale-audit-state-file", "", "File used to persist the configuration audit log cursor across restarts (can also be set via TAILSCALE_AUDIT_STATE_FILE environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleFlowLogsAggregate, "tailscale-flow-logs-aggregation", "", "Enable network flow log traffic counters aggregated by device, tag or user (can also be set via TAILSCALE_FLOW_LOGS_AGGREGATION environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleDNSProbeQuery, "tailscale-dns-probe-query", "", "Enable probing of the configured nameservers with this query name; split DNS nameservers are queried for their domain (can also be set via TAILSCALE_DNS_PROBE_QUERY environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleDNSProbeQueryType, "tailscale-dns-probe-query-type", "A", "Record type of DNS probe queries (can also be set via TAILSCALE_DNS_PROBE_QUERY_TYPE environment variable)")
+	rootCmd.PersistentFlags().
+		DurationVar(&tailscaleDNSProbeTimeout, "tailscale-dns-probe-timeout", 2*time.Second, "Timeout of each DNS probe query (can also be set via TAILSCALE_DNS_PROBE_TIMEOUT environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleLifecycleStateFile, "tailscale-lifecycle-state-file", "", "File used to persist the devices seen by the lifecycle collector across restarts (can also be set via TAILSCALE_LIFECYCLE_STATE_FILE environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleWebhookSecret, "tailscale-webhook-secret", "", "Enable the "+tailscale.WebhookReceiverPath+" endpoint and verify deliveries with this webhook secret (can also be set via TAILSCALE_WEBHOOK_SECRET environment variable)")
+	rootCmd.PersistentFlags().
+		StringVar(&tailscaleDERPLatencyMode, "tailscale-derp-latency-mode", tailscale.DERPLatencyModeDevice, "How device DERP latency is exported: device, aggregated or both (can also be set via TAILSCALE_DERP_LATENCY_MODE environment variable)")
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
@@ -97,30 +226,112 @@ func init() {
 	mustBindFlag("metrics-path")
 	mustBindFlag("read-timeout")
 	mustBindFlag("write-timeout")
+	mustBindFlag("sd-target-port")
+	mustBindFlag("sd-file")
+	mustBindFlag("sd-file-format")
+	mustBindFlag("sd-file-filter")
+	mustBindFlag("sd-file-labels")
+	mustBindFlag("sd-file-interval")
+	mustBindFlag("machines")
+	mustBindFlag("machines-concurrency")
+	mustBindFlag("machines-timeout")
+	mustBindFlag("tsnet-hostname")
+	mustBindFlag("tsnet-auth-key")
+	mustBindFlag("tsnet-state-dir")
+	mustBindFlag("tsnet-control-url")
+	mustBindFlag("tsnet-listen-address")
+	mustBindFlag("tsnet-keep-listen-address")
 
 	// Tailscale flags
 	mustBindFlag("tailscale-tailnet")
 	mustBindFlag("tailscale-oauth-client-id")
 	mustBindFlag("tailscale-oauth-client-secret")
+	mustBindFlag("tailscale-derp-latency-mode")
+	mustBindFlag("tailscale-contacts")
+	mustBindFlag("tailscale-contacts-hash-key")
+	mustBindFlag("tailscale-webhooks")
+	mustBindFlag("tailscale-log-streaming")
+	mustBindFlag("tailscale-audit")
+	mustBindFlag("tailscale-keys-long-lived-threshold")
+	mustBindFlag("tailscale-audit-state-file")
+	mustBindFlag("tailscale-flow-logs-aggregation")
+	mustBindFlag("tailscale-dns-probe-query")
+	mustBindFlag("tailscale-dns-probe-query-type")
+	mustBindFlag("tailscale-dns-probe-timeout")
+	mustBindFlag("tailscale-lifecycle-state-file")
+	mustBindFlag("tailscale-webhook-secret")
 
 	// Headscale flags
 	mustBindFlag("headscale-address")
 	mustBindFlag("headscale-api-key")
 	mustBindFlag("headscale-insecure")
+	mustBindFlag("headscale-lifecycle-state-file")
+	mustBindFlag("headscale-api-key-file")
+	mustBindFlag("headscale-api-key-warn-before")
+	mustBindFlag("headscale-api-key-rotate-before")
+	mustBindFlag("headscale-api-key-lifetime")
+
+	// Local tailscaled flags
+	mustBindFlag("local-socket")
+	mustBindFlag("local-probe-peers")
+	mustBindFlag("local-probe-type")
+	mustBindFlag("local-probe-port")
+	mustBindFlag("local-probe-timeout")
 
 	// Tailscale flags
 	mustBindEnv("tailscale-tailnet", "TAILSCALE_TAILNET")
 	mustBindEnv("tailscale-oauth-client-id", "TAILSCALE_OAUTH_CLIENT_ID")
 	mustBindEnv("tailscale-oauth-client-secret", "TAILSCALE_OAUTH_CLIENT_SECRET")
+	mustBindEnv("tailscale-derp-latency-mode", "TAILSCALE_DERP_LATENCY_MODE")
+	mustBindEnv("tailscale-contacts", "TAILSCALE_CONTACTS")
+	mustBindEnv("tailscale-contacts-hash-key", "TAILSCALE_CONTACTS_HASH_KEY")
+	mustBindEnv("tailscale-webhooks", "TAILSCALE_WEBHOOKS")
+	mustBindEnv("tailscale-log-streaming", "TAILSCALE_LOG_STREAMING")
+	mustBindEnv("tailscale-audit", "TAILSCALE_AUDIT")
+	mustBindEnv("tailscale-keys-long-lived-threshold", "TAILSCALE_KEYS_LONG_LIVED_THRESHOLD")
+	mustBindEnv("tailscale-audit-state-file", "TAILSCALE_AUDIT_STATE_FILE")
+	mustBindEnv("tailscale-flow-logs-aggregation", "TAILSCALE_FLOW_LOGS_AGGREGATION")
+	mustBindEnv("tailscale-dns-probe-query", "TAILSCALE_DNS_PROBE_QUERY")
+	mustBindEnv("tailscale-dns-probe-query-type", "TAILSCALE_DNS_PROBE_QUERY_TYPE")
+	mustBindEnv("tailscale-dns-probe-timeout", "TAILSCALE_DNS_PROBE_TIMEOUT")
+	mustBindEnv("tailscale-lifecycle-state-file", "TAILSCALE_LIFECYCLE_STATE_FILE")
+	mustBindEnv("tailscale-webhook-secret", "TAILSCALE_WEBHOOK_SECRET")
 
 	// Headscale flags
 	mustBindEnv("headscale-address", "HEADSCALE_ADDRESS")
 	mustBindEnv("headscale-api-key", "HEADSCALE_API_KEY")
 	mustBindEnv("headscale-insecure", "HEADSCALE_INSECURE")
+	mustBindEnv("headscale-lifecycle-state-file", "HEADSCALE_LIFECYCLE_STATE_FILE")
+	mustBindEnv("headscale-api-key-file", "HEADSCALE_API_KEY_FILE")
+	mustBindEnv("headscale-api-key-warn-before", "HEADSCALE_API_KEY_WARN_BEFORE")
+	mustBindEnv("headscale-api-key-rotate-before", "HEADSCALE_API_KEY_ROTATE_BEFORE")
+	mustBindEnv("headscale-api-key-lifetime", "HEADSCALE_API_KEY_LIFETIME")
+
+	// Local tailscaled flags
+	mustBindEnv("local-socket", "LOCAL_SOCKET")
+	mustBindEnv("local-probe-peers", "LOCAL_PROBE_PEERS")
+	mustBindEnv("local-probe-type", "LOCAL_PROBE_TYPE")
+	mustBindEnv("local-probe-port", "LOCAL_PROBE_PORT")
+	mustBindEnv("local-probe-timeout", "LOCAL_PROBE_TIMEOUT")
 
 	// Server timeouts
 	mustBindEnv("read-timeout", "READ_TIMEOUT")
 	mustBindEnv("write-timeout", "WRITE_TIMEOUT")
+	mustBindEnv("sd-target-port", "SD_TARGET_PORT")
+	mustBindEnv("sd-file", "SD_FILE")
+	mustBindEnv("sd-file-format", "SD_FILE_FORMAT")
+	mustBindEnv("sd-file-filter", "SD_FILE_FILTER")
+	mustBindEnv("sd-file-labels", "SD_FILE_LABELS")
+	mustBindEnv("sd-file-interval", "SD_FILE_INTERVAL")
+	mustBindEnv("machines", "MACHINES")
+	mustBindEnv("machines-concurrency", "MACHINES_CONCURRENCY")
+	mustBindEnv("machines-timeout", "MACHINES_TIMEOUT")
+	mustBindEnv("tsnet-hostname", "TSNET_HOSTNAME")
+	mustBindEnv("tsnet-auth-key", "TSNET_AUTH_KEY")
+	mustBindEnv("tsnet-state-dir", "TSNET_STATE_DIR")
+	mustBindEnv("tsnet-control-url", "TSNET_CONTROL_URL")
+	mustBindEnv("tsnet-listen-address", "TSNET_LISTEN_ADDRESS")
+	mustBindEnv("tsnet-keep-listen-address", "TSNET_KEEP_LISTEN_ADDRESS")
 }
 
 func runExporter(cmd *cobra.Command, args []string) error {
@@ -141,23 +352,74 @@ func runExporter(cmd *cobra.Command, args []string) error {
 	metricsPath = strings.TrimSpace(viper.GetString("metrics-path"))
 	readTimeout = viper.GetDuration("read-timeout")
 	writeTimeout = viper.GetDuration("write-timeout")
+	sdTargetPort = viper.GetInt("sd-target-port")
+	sdFile = strings.TrimSpace(viper.GetString("sd-file"))
+	sdFileFormat = strings.TrimSpace(viper.GetString("sd-file-format"))
+	sdFileFilter = strings.TrimSpace(viper.GetString("sd-file-filter"))
+	sdFileLabels = strings.TrimSpace(viper.GetString("sd-file-labels"))
+	sdFileInterval = viper.GetDuration("sd-file-interval")
+	machinesEnabled = viper.GetBool("machines")
+	machinesConcurrency = viper.GetInt("machines-concurrency")
+	machinesTimeout = viper.GetDuration("machines-timeout")
+	tsnetHostname = strings.TrimSpace(viper.GetString("tsnet-hostname"))
+	tsnetAuthKey = strings.TrimSpace(viper.GetString("tsnet-auth-key"))
+	tsnetStateDir = strings.TrimSpace(viper.GetString("tsnet-state-dir"))
+	tsnetControlURL = strings.TrimSpace(viper.GetString("tsnet-control-url"))
+	tsnetListenAddress = strings.TrimSpace(viper.GetString("tsnet-listen-address"))
+	tsnetKeepListenAddress = viper.GetBool("tsnet-keep-listen-address")
 
 	// Tailscale
 	tailscaleTailnet = strings.TrimSpace(viper.GetString("tailscale-tailnet"))
 	tailscaleOauthClientID = strings.TrimSpace(viper.GetString("tailscale-oauth-client-id"))
 	tailscaleOauthClientSecret = strings.TrimSpace(viper.GetString("tailscale-oauth-client-secret"))
+	tailscaleDERPLatencyMode = strings.TrimSpace(viper.GetString("tailscale-derp-latency-mode"))
+	tailscaleContacts = viper.GetBool("tailscale-contacts")
+	tailscaleContactsHashKey = strings.TrimSpace(viper.GetString("tailscale-contacts-hash-key"))
+	tailscaleWebhooks = viper.GetBool("tailscale-webhooks")
+	tailscaleLogStreaming = viper.GetBool("tailscale-log-streaming")
+	tailscaleAudit = viper.GetBool("tailscale-audit")
+	tailscaleKeysLongLivedThreshold = viper.GetDuration("tailscale-keys-long-lived-threshold")
+	tailscaleAuditStateFile = strings.TrimSpace(viper.GetString("tailscale-audit-state-file"))
+	tailscaleFlowLogsAggregate = strings.TrimSpace(viper.GetString("tailscale-flow-logs-aggregation"))
+	tailscaleDNSProbeQuery = strings.TrimSpace(viper.GetString("tailscale-dns-probe-query"))
+	tailscaleDNSProbeQueryType = strings.TrimSpace(viper.GetString("tailscale-dns-probe-query-type"))
+	tailscaleDNSProbeTimeout = viper.GetDuration("tailscale-dns-probe-timeout")
+	tailscaleLifecycleStateFile = strings.TrimSpace(viper.GetString("tailscale-lifecycle-state-file"))
+	tailscaleWebhookSecret = strings.TrimSpace(viper.GetString("tailscale-webhook-secret"))
 
 	// Headscale
 	headscaleAddress = strings.TrimSpace(viper.GetString("headscale-address"))
 	headscaleAPIKey = strings.TrimSpace(viper.GetString("headscale-api-key"))
 	headscaleInsecure = viper.GetBool("headscale-insecure")
+	headscaleLifecycleStateFile = strings.TrimSpace(viper.GetString("headscale-lifecycle-state-file"))
+	headscaleAPIKeyFile = strings.TrimSpace(viper.GetString("headscale-api-key-file"))
+	headscaleAPIKeyWarnBefore = viper.GetDuration("headscale-api-key-warn-before")
+	headscaleAPIKeyRotateBefore = viper.GetDuration("headscale-api-key-rotate-before")
+	headscaleAPIKeyLifetime = viper.GetDuration("headscale-api-key-lifetime")
+
+	// Local tailscaled
+	localSocket = strings.TrimSpace(viper.GetString("local-socket"))
+	localProbePeers = strings.TrimSpace(viper.GetString("local-probe-peers"))
+	localProbeType = strings.TrimSpace(viper.GetString("local-probe-type"))
+	localProbePort = viper.GetInt("local-probe-port")
+	localProbeTimeout = viper.GetDuration("local-probe-timeout")
+
+	var sdStore *sd.Store
+	if sdFile != "" {
+		sdStore = sd.NewStore()
+	}
 
 	registered := false
+	var machineSources []sd.Source
+	sdSources := make(map[string]sd.Source)
 
 	if tailscaleTailnet != "" {
 		if tailscaleOauthClientID == "" || tailscaleOauthClientSecret == "" {
 			return errors.New("oauth credentials are required when tailnet is set")
 		}
+		if tailscaleContacts && tailscaleContactsHashKey == "" {
+			return errors.New("--tailscale-contacts-hash-key is required when contacts are enabled")
+		}
 
 		oauthConfig := &clientcredentials.Config{
 			ClientID:     tailscaleOauthClientID,
@@ -175,6 +437,21 @@ func runExporter(cmd *cobra.Command, args []string) error {
 				"policy_file:read",
 			},
 		}
+		if tailscaleContacts {
+			oauthConfig.Scopes = append(oauthConfig.Scopes, "account_settings:read")
+		}
+		if tailscaleWebhooks {
+			oauthConfig.Scopes = append(oauthConfig.Scopes, "webhooks:read")
+		}
+		if tailscaleLogStreaming {
+			oauthConfig.Scopes = append(oauthConfig.Scopes, "log_streaming:read")
+		}
+		if tailscaleAudit {
+			oauthConfig.Scopes = append(oauthConfig.Scopes, "logs:configuration:read")
+		}
+		if tailscaleFlowLogsAggregate != "" {
+			oauthConfig.Scopes = append(oauthConfig.Scopes, "logs:network:read")
+		}
 
 		httpClient := oauthConfig.Client(context.Background())
 		httpClient.Transport = newRetryTransport(httpClient.Transport)
@@ -189,6 +466,22 @@ func runExporter(cmd *cobra.Command, args []string) error {
 			logger,
 			httpClient,
 			tailscaleTailnet,
+			tailscale.Config{
+				DERPLatencyMode:        tailscaleDERPLatencyMode,
+				Contacts:               tailscaleContacts,
+				ContactsHashKey:        tailscaleContactsHashKey,
+				Webhooks:               tailscaleWebhooks,
+				LogStreaming:           tailscaleLogStreaming,
+				Audit:                  tailscaleAudit,
+				KeysLongLivedThreshold: tailscaleKeysLongLivedThreshold,
+				AuditStateFile:         tailscaleAuditStateFile,
+				FlowLogsAggregation:    tailscaleFlowLogsAggregate,
+				DNSProbeQuery:          tailscaleDNSProbeQuery,
+				DNSProbeQueryType:      tailscaleDNSProbeQueryType,
+				DNSProbeTimeout:        tailscaleDNSProbeTimeout,
+				LifecycleStateFile:     tailscaleLifecycleStateFile,
+				SDStore:                sdStore,
+			},
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create Tailscale collector: %w", err)
@@ -199,6 +492,13 @@ func runExporter(cmd *cobra.Command, args []string) error {
 			prometheus.DefaultRegisterer,
 		)
 		tsReg.MustRegister(tsCollector)
+		http.Handle(sd.DevicesPath, sd.Handler(
+			logger.With("system", "sd"),
+			tsCollector.ServiceDiscoveryDevices,
+			sdTargetPort,
+		))
+		machineSources = append(machineSources, tsCollector.ServiceDiscoveryDevices)
+		sdSources["tailscale"] = tsCollector.ServiceDiscoveryDevices
 		registered = true
 		logger.Info("Tailscale metrics enabled", "tailnet", tailscaleTailnet)
 	} else {
@@ -207,11 +507,23 @@ func runExporter(cmd *cobra.Command, args []string) error {
 
 	// Optional Headscale metrics.
 	if headscaleAddress != "" {
+		// A key file that exists holds a rotated key, which replaces the
+		// configured one.
+		if headscaleAPIKeyFile != "" {
+			key, err := headscaleCollector.ReadAPIKeyFile(headscaleAPIKeyFile)
+			switch {
+			case err == nil && key != "":
+				headscaleAPIKey = key
+			case err != nil && !errors.Is(err, os.ErrNotExist):
+				return fmt.Errorf("failed to read Headscale API key file: %w", err)
+			}
+		}
 		if headscaleAPIKey == "" {
 			return errors.New(
 				"HEADSCALE_API_KEY (or --headscale-api-key) is required when HEADSCALE_ADDRESS is set",
 			)
 		}
+		apiKey := headscaleCollector.NewAPIKey(headscaleAPIKey, headscaleAPIKeyFile)
 
 		var transportCreds credentials.TransportCredentials
 		if headscaleInsecure {
@@ -236,35 +548,198 @@ func runExporter(cmd *cobra.Command, args []string) error {
 			}
 		}()
 
-		hsClient := headscaleCollector.NewGRPCHeadscaleClient(
-			headscalev1.NewHeadscaleServiceClient(conn),
-			headscaleAPIKey,
-		)
+		hsClient := headscaleCollector.NewGRPCHeadscaleClient(conn, apiKey)
 		hsCollector, err := headscaleCollector.NewHeadscaleCollector(
 			logger.With("system", "headscale"),
 			hsClient,
+			headscaleCollector.Config{
+				LifecycleStateFile: headscaleLifecycleStateFile,
+				SDStore:            sdStore,
+				APIKey:             apiKey,
+				APIKeyWarnBefore:   headscaleAPIKeyWarnBefore,
+				APIKeyRotateBefore: headscaleAPIKeyRotateBefore,
+				APIKeyLifetime:     headscaleAPIKeyLifetime,
+			},
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create Headscale collector: %w", err)
 		}
 		prometheus.DefaultRegisterer.MustRegister(hsCollector)
+		http.Handle(sd.NodesPath, sd.Handler(
+			logger.With("system", "sd"),
+			hsCollector.ServiceDiscoveryNodes,
+			sdTargetPort,
+		))
+		machineSources = append(machineSources, hsCollector.ServiceDiscoveryNodes)
+		sdSources["headscale"] = hsCollector.ServiceDiscoveryNodes
 		registered = true
 		logger.Info("Headscale metrics enabled", "address", headscaleAddress)
 	} else {
 		logger.Info("Headscale metrics disabled", "reason", "HEADSCALE_ADDRESS not set")
 	}
 
+	var tsServer *tsnet.Server
+	if tsnetHostname != "" {
+		tsServer = newTsnetServer(logger.With("system", "tsnet"), tsnetConfig{
+			hostname:   tsnetHostname,
+			authKey:    tsnetAuthKey,
+			stateDir:   tsnetStateDir,
+			controlURL: tsnetControlURL,
+		})
+		defer func() {
+			if err := tsServer.Close(); err != nil {
+				logger.Error("Failed to close tsnet node", "error", err)
+			}
+		}()
+	}
+
+	// Optional local tailscaled metrics. Without a socket, peers can still be
+	// probed from the tsnet node.
+	if localSocket != "" || (tsServer != nil && localProbePeers != "") {
+		probeQuery, err := url.ParseQuery(localProbePeers)
+		if err != nil {
+			return fmt.Errorf("invalid local probe peers: %w", err)
+		}
+
+		var localClient *local.LocalAPIClient
+		if localSocket != "" {
+			localClient = local.NewLocalAPIClient(localSocket)
+		} else {
+			lc, err := tsServer.LocalClient()
+			if err != nil {
+				return fmt.Errorf("failed to get tsnet local client: %w", err)
+			}
+			localClient = local.WrapLocalAPIClient(lc)
+		}
+
+		localCollector, err := local.NewLocalCollector(
+			logger.With("system", "local"),
+			localClient,
+			local.Config{
+				ProbeTags:    probeQuery["tag"],
+				ProbeUsers:   probeQuery["user"],
+				ProbeNames:   probeQuery["name"],
+				ProbeType:    localProbeType,
+				ProbePort:    localProbePort,
+				ProbeTimeout: localProbeTimeout,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create local collector: %w", err)
+		}
+		prometheus.DefaultRegisterer.MustRegister(localCollector)
+		registered = true
+		logger.Info("Local tailscaled metrics enabled", "socket", localSocket, "probe_peers", localProbePeers)
+	}
+
 	if !registered {
 		logger.Error(
 			"No collectors enabled",
 			"action",
-			"set --tailscale-tailnet or --headscale-address",
+			"set --tailscale-tailnet, --headscale-address or --local-socket",
 		)
-		return errors.New("at least one metrics source (tailnet or headscale) must be configured")
+		return errors.New("at least one metrics source (tailnet, headscale or local socket) must be configured")
+	}
+
+	var sdWriter *sd.FileWriter
+	if sdStore != nil {
+		if sdFileInterval <= 0 {
+			return errors.New("--sd-file-interval must be positive")
+		}
+		filterQuery, err := url.ParseQuery(sdFileFilter)
+		if err != nil {
+			return fmt.Errorf("invalid service discovery file filter: %w", err)
+		}
+		filter, err := sd.ParseFilter(filterQuery)
+		if err != nil {
+			return fmt.Errorf("invalid service discovery file filter: %w", err)
+		}
+		var labels []string
+		for label := range strings.SplitSeq(sdFileLabels, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				labels = append(labels, label)
+			}
+		}
+
+		sdWriter, err = sd.NewFileWriter(
+			logger.With("system", "sd"),
+			sdStore,
+			sdSources,
+			sdFile,
+			sdFileFormat,
+			sdTargetPort,
+			filter,
+			labels,
+		)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go sdWriter.Run(ctx, sdFileInterval)
+		logger.Info("Service discovery file enabled", "path", sdFile, "format", sdFileFormat)
 	}
 
 	// Create HTTP server
 	http.Handle(metricsPath, promhttp.Handler())
+	// The server only answers once its listeners are up.
+	http.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err := w.Write([]byte("ok\n")); err != nil {
+			logger.Error("Error writing response", "err", err)
+		}
+	})
+
+	if machinesEnabled {
+		if machinesConcurrency <= 0 {
+			return errors.New("--machines-concurrency must be positive")
+		}
+		if machinesTimeout <= 0 {
+			return errors.New("--machines-timeout must be positive")
+		}
+		machinesConfig := machines.Config{
+			Port:        sdTargetPort,
+			Concurrency: machinesConcurrency,
+			Timeout:     machinesTimeout,
+		}
+		// The tsnet node is not reachable through the host network stack.
+		if tsServer != nil {
+			machinesConfig.Client = tsServer.HTTPClient()
+		}
+		gatherer := machines.NewGatherer(
+			logger.With("system", "machines"),
+			machinesConfig,
+			machineSources...,
+		)
+		http.Handle(machines.Path, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+		logger.Info("Machines mode enabled", "path", machines.Path, "port", sdTargetPort)
+	}
+
+	if tailscaleWebhookSecret != "" {
+		receiver := tailscale.NewWebhookReceiver(
+			logger.With("system", "webhooks"),
+			tailscaleWebhookSecret,
+		)
+		// Devices added or removed are written to the service discovery
+		// file without waiting for the next interval.
+		if sdWriter != nil {
+			receiver.OnEvent = func(eventType string) {
+				switch eventType {
+				case tailscale.WebhookEventNodeCreated,
+					tailscale.WebhookEventNodeDeleted,
+					tailscale.WebhookEventNodeApproved:
+					go func() {
+						ctx, cancel := context.WithTimeout(context.Background(), sdFileInterval)
+						defer cancel()
+						sdWriter.Refresh(ctx)
+					}()
+				}
+			}
+		}
+		prometheus.DefaultRegisterer.MustRegister(receiver)
+		http.Handle(tailscale.WebhookReceiverPath, receiver)
+		logger.Info("Tailscale webhook receiver enabled", "path", tailscale.WebhookReceiverPath)
+	}
 
 	// Root handler with simple landing page
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -274,6 +749,7 @@ func runExporter(cmd *cobra.Command, args []string) error {
 			<body>
 			<h1>Tailscale Exporter</h1>
 			<p><a href='` + metricsPath + `'>Metrics</a></p>
+			<p><a href='` + healthPath + `'>Health</a></p>
 			</body>
 			</html>`))
 		if err != nil {
@@ -282,7 +758,6 @@ func runExporter(cmd *cobra.Command, args []string) error {
 	})
 
 	server := &http.Server{
-		Addr:         listenAddress,
 		Handler:      nil,
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
@@ -303,13 +778,46 @@ func runExporter(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	logger.Info("Listening",
-		"address", listenAddress,
-		"read_timeout", readTimeout,
-		"write_timeout", writeTimeout,
-	)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		return fmt.Errorf("HTTP server failed: %w", err)
+	// With a tsnet node the exporter is only reachable from the tailnet,
+	// unless --listen-address is kept.
+	var listeners []net.Listener
+	if tsServer == nil || tsnetKeepListenAddress {
+		ln, err := net.Listen("tcp", listenAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", listenAddress, err)
+		}
+		listeners = append(listeners, ln)
+		logger.Info("Listening",
+			"address", listenAddress,
+			"read_timeout", readTimeout,
+			"write_timeout", writeTimeout,
+		)
+	}
+	if tsServer != nil {
+		ln, err := tsServer.Listen("tcp", tsnetListenAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on tailnet address %s: %w", tsnetListenAddress, err)
+		}
+		listeners = append(listeners, ln)
+		logger.Info("Listening on tailnet",
+			"hostname", tsnetHostname,
+			"address", tsnetListenAddress,
+			"read_timeout", readTimeout,
+			"write_timeout", writeTimeout,
+		)
+	}
+
+	serveErrs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			serveErrs <- server.Serve(ln)
+		}()
+	}
+	for range listeners {
+		if err := <-serveErrs; err != http.ErrServerClosed {
+			_ = server.Close()
+			return fmt.Errorf("HTTP server failed: %w", err)
+		}
 	}
 
 	logger.Info("Tailscale exporter stopped")
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"tailscale.com/tsnet"
+)
+
+type tsnetConfig struct {
+	hostname   string
+	authKey    string
+	stateDir   string
+	controlURL string
+}
+
+// newTsnetServer returns an embedded tailnet node. The node joins the tailnet
+// when it first listens or dials; without an auth key a login URL is logged.
+func newTsnetServer(logger *slog.Logger, config tsnetConfig) *tsnet.Server {
+	return &tsnet.Server{
+		Hostname:   config.hostname,
+		AuthKey:    config.authKey,
+		Dir:        config.stateDir,
+		ControlURL: config.controlURL,
+		Logf: func(format string, args ...any) {
+			logger.Debug(fmt.Sprintf(format, args...))
+		},
+		UserLogf: func(format string, args ...any) {
+			logger.Info(fmt.Sprintf(format, args...))
+		},
+	}
+}
@@ -2,13 +2,19 @@ package headscale
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
 )
 
 const (
@@ -42,10 +48,35 @@ var (
 		"headscale_exporter: Whether a collector succeeded.",
 		[]string{"collector"},
 	)
+	scrapeSupportedDesc = newDesc(
+		"scrape",
+		"collector_supported",
+		"headscale_exporter: Whether the Headscale server implements the RPCs of a collector.",
+		[]string{"collector"},
+	)
 )
 
+// Config holds optional settings shared by the Headscale collectors.
+type Config struct {
+	// LifecycleStateFile, when set, persists the nodes seen by the lifecycle
+	// collector so that changes during a restart are counted.
+	LifecycleStateFile string
+	// SDStore, when set, receives the nodes listed by the nodes collector for
+	// file based service discovery.
+	SDStore *sd.Store
+	// APIKey, when set, is the API key of the exporter whose expiry is
+	// tracked. A warning is logged APIKeyWarnBefore it expires, and with
+	// APIKeyRotateBefore set it is replaced by a new key valid for
+	// APIKeyLifetime.
+	APIKey             *APIKey
+	APIKeyWarnBefore   time.Duration
+	APIKeyRotateBefore time.Duration
+	APIKeyLifetime     time.Duration
+}
+
 type collectorConfig struct {
 	logger *slog.Logger
+	Config
 }
 
 type Collector interface {
@@ -54,6 +85,7 @@ type Collector interface {
 
 type HeadscaleCollector struct {
 	client     HeadscaleClient
+	cacheStats *snapshot.Stats
 	Collectors map[string]Collector
 	logger     *slog.Logger
 }
@@ -64,28 +96,42 @@ type HeadscaleClient interface {
 	ListAPIKeys(ctx context.Context) ([]*headscalev1.ApiKey, error)
 	ListPreAuthKeys(ctx context.Context) ([]*headscalev1.PreAuthKey, error)
 	Health(ctx context.Context) (*headscalev1.HealthResponse, error)
+	GetPolicy(ctx context.Context) (*headscalev1.GetPolicyResponse, error)
+	// ServerVersion returns the detected API version of the server, one of
+	// the APIVersion constants.
+	ServerVersion(ctx context.Context) (string, error)
+	// CreateAPIKey creates an API key expiring at expiration and returns it.
+	CreateAPIKey(ctx context.Context, expiration time.Time) (string, error)
+	// ExpireAPIKey expires the API key with prefix.
+	ExpireAPIKey(ctx context.Context, prefix string) error
 }
 
 type grpcHeadscaleClient struct {
-	client headscalev1.HeadscaleServiceClient
-	apiKey string
+	conn    grpc.ClientConnInterface
+	client  headscalev1.HeadscaleServiceClient
+	apiKey  *APIKey
+	version serverVersion
 }
 
+// NewGRPCHeadscaleClient returns a client for the Headscale API on conn that
+// adapts its calls to the API version of the server.
 func NewGRPCHeadscaleClient(
-	client headscalev1.HeadscaleServiceClient,
-	apiKey string,
+	conn grpc.ClientConnInterface,
+	apiKey *APIKey,
 ) HeadscaleClient {
 	return &grpcHeadscaleClient{
-		client: client,
+		conn:   conn,
+		client: headscalev1.NewHeadscaleServiceClient(conn),
 		apiKey: apiKey,
 	}
 }
 
 func (c *grpcHeadscaleClient) ctxWithAuth(ctx context.Context) context.Context {
-	if c.apiKey == "" {
+	key := c.apiKey.Key()
+	if key == "" {
 		return ctx
 	}
-	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.apiKey)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
 }
 
 func (c *grpcHeadscaleClient) ListUsers(ctx context.Context) ([]*headscalev1.User, error) {
@@ -98,12 +144,22 @@ func (c *grpcHeadscaleClient) ListUsers(ctx context.Context) ([]*headscalev1.Use
 }
 
 func (c *grpcHeadscaleClient) ListNodes(ctx context.Context) ([]*headscalev1.Node, error) {
-	ctx = c.ctxWithAuth(ctx)
-	resp, err := c.client.ListNodes(ctx, &headscalev1.ListNodesRequest{})
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.ListNodes(c.ctxWithAuth(ctx), &headscalev1.ListNodesRequest{})
 	if err != nil {
 		return nil, err
 	}
-	return resp.GetNodes(), nil
+	nodes := resp.GetNodes()
+	if version == APIVersion023 {
+		if err := c.addLegacyRoutes(ctx, nodes); err != nil {
+			return nil, fmt.Errorf("listing routes: %w", err)
+		}
+	}
+	return nodes, nil
 }
 
 func (c *grpcHeadscaleClient) ListAPIKeys(ctx context.Context) ([]*headscalev1.ApiKey, error) {
@@ -118,9 +174,15 @@ func (c *grpcHeadscaleClient) ListAPIKeys(ctx context.Context) ([]*headscalev1.A
 func (c *grpcHeadscaleClient) ListPreAuthKeys(
 	ctx context.Context,
 ) ([]*headscalev1.PreAuthKey, error) {
-	ctx = c.ctxWithAuth(ctx)
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version != APIVersion028 {
+		return c.listPreAuthKeysByUser(ctx, version)
+	}
 
-	resp, err := c.client.ListPreAuthKeys(ctx, &headscalev1.ListPreAuthKeysRequest{})
+	resp, err := c.client.ListPreAuthKeys(c.ctxWithAuth(ctx), &headscalev1.ListPreAuthKeysRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -132,6 +194,30 @@ func (c *grpcHeadscaleClient) Health(ctx context.Context) (*headscalev1.HealthRe
 	return c.client.Health(ctx, &headscalev1.HealthRequest{})
 }
 
+func (c *grpcHeadscaleClient) GetPolicy(ctx context.Context) (*headscalev1.GetPolicyResponse, error) {
+	ctx = c.ctxWithAuth(ctx)
+	return c.client.GetPolicy(ctx, &headscalev1.GetPolicyRequest{})
+}
+
+func (c *grpcHeadscaleClient) CreateAPIKey(ctx context.Context, expiration time.Time) (string, error) {
+	ctx = c.ctxWithAuth(ctx)
+	resp, err := c.client.CreateApiKey(ctx, &headscalev1.CreateApiKeyRequest{
+		Expiration: timestamppb.New(expiration),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetApiKey(), nil
+}
+
+func (c *grpcHeadscaleClient) ExpireAPIKey(ctx context.Context, prefix string) error {
+	ctx = c.ctxWithAuth(ctx)
+	_, err := c.client.ExpireApiKey(ctx, &headscalev1.ExpireApiKeyRequest{
+		Prefix: prefix,
+	})
+	return err
+}
+
 func newDesc(subsystem, name, help string, variableLabels []string) *prometheus.Desc {
 	return prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, subsystem, name),
@@ -148,10 +234,12 @@ func registerCollector(name string, createFunc func(collectorConfig) (Collector,
 func NewHeadscaleCollector(
 	logger *slog.Logger,
 	client HeadscaleClient,
+	config Config,
 ) (*HeadscaleCollector, error) {
 	h := &HeadscaleCollector{
-		logger: logger,
-		client: client,
+		logger:     logger,
+		client:     client,
+		cacheStats: snapshot.NewStats(),
 	}
 
 	collectors := make(map[string]Collector)
@@ -163,10 +251,15 @@ func NewHeadscaleCollector(
 		} else {
 			coll, err := factories[key](collectorConfig{
 				logger: logger.With("collector", key),
+				Config: config,
 			})
 			if err != nil {
 				return nil, err
 			}
+			if coll == nil {
+				// The collector is disabled by its configuration.
+				continue
+			}
 			collectors[key] = coll
 			initiatedCollectors[key] = coll
 		}
@@ -180,6 +273,9 @@ func (h *HeadscaleCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- upDesc
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- scrapeSupportedDesc
+	ch <- scrapeCacheHitsDesc
+	ch <- scrapeCacheMissesDesc
 }
 
 func (h *HeadscaleCollector) Collect(ch chan<- prometheus.Metric) {
@@ -187,13 +283,16 @@ func (h *HeadscaleCollector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
 	wg.Add(len(h.Collectors))
 
+	// Every collector of this scrape reads from the same snapshot.
+	client := newSnapshotClient(h.client, h.cacheStats)
 	for name, c := range h.Collectors {
 		go func(name string, c Collector) {
-			execute(ctx, name, c, h.client, ch, h.logger)
+			execute(ctx, name, c, client, ch, h.logger)
 			wg.Done()
 		}(name, c)
 	}
 	wg.Wait()
+	h.cacheStats.Collect(ch, scrapeCacheHitsDesc, scrapeCacheMissesDesc)
 	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
 }
 
@@ -210,6 +309,22 @@ func execute(
 	duration := time.Since(begin)
 	var success float64
 
+	// Collectors of RPCs the server does not implement are unsupported
+	// rather than failed.
+	if IsUnimplemented(err) {
+		logger.DebugContext(
+			ctx,
+			"collector unsupported by server",
+			"name",
+			name,
+			"err",
+			err,
+		)
+		ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(scrapeSupportedDesc, prometheus.GaugeValue, 0, name)
+		return
+	}
+
 	if err != nil {
 		logger.ErrorContext(
 			ctx,
@@ -235,4 +350,5 @@ func execute(
 	}
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	ch <- prometheus.MustNewConstMetric(scrapeSupportedDesc, prometheus.GaugeValue, 1, name)
 }
@@ -0,0 +1,277 @@
+package headscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// API versions of Headscale servers. Headscale does not report its version
+// over gRPC, so it is detected from the RPCs the server implements and the
+// versions are the releases sharing an API.
+const (
+	APIVersion023 = "0.23-0.25"
+	APIVersion026 = "0.26"
+	APIVersion027 = "0.27"
+	APIVersion028 = "0.28+"
+)
+
+// versionCheckInterval is how long a detected version is used before it is
+// detected again, so that server upgrades are picked up.
+const versionCheckInterval = 10 * time.Minute
+
+// getRoutesMethod lists the routes of all nodes on servers before 0.26, which
+// did not report routes in nodes. It was removed from the current client.
+const getRoutesMethod = "/headscale.v1.HeadscaleService/GetRoutes"
+
+// serverVersion caches the detected API version of a server.
+type serverVersion struct {
+	mtx        sync.Mutex
+	version    string
+	detectedAt time.Time
+}
+
+// IsUnimplemented reports whether err is the gRPC error of an RPC the server
+// does not implement.
+func IsUnimplemented(err error) bool {
+	return status.Code(err) == codes.Unimplemented
+}
+
+// ServerVersion returns the API version of the server.
+func (c *grpcHeadscaleClient) ServerVersion(ctx context.Context) (string, error) {
+	c.version.mtx.Lock()
+	defer c.version.mtx.Unlock()
+
+	if c.version.version != "" && time.Since(c.version.detectedAt) < versionCheckInterval {
+		return c.version.version, nil
+	}
+	version, err := c.detectVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("detecting Headscale version: %w", err)
+	}
+	c.version.version = version
+	c.version.detectedAt = time.Now()
+	return version, nil
+}
+
+// detectVersion probes read-only RPCs: Health was added in 0.27, GetRoutes
+// removed in 0.26 and 0.28 lists pre-auth keys without a user.
+func (c *grpcHeadscaleClient) detectVersion(ctx context.Context) (string, error) {
+	ctx = c.ctxWithAuth(ctx)
+
+	_, err := c.client.Health(ctx, &headscalev1.HealthRequest{})
+	switch {
+	case err == nil:
+		_, err := c.client.ListPreAuthKeys(ctx, &headscalev1.ListPreAuthKeysRequest{})
+		if err == nil {
+			return APIVersion028, nil
+		}
+		// Before 0.28 listing pre-auth keys fails without a user.
+		if isMissingUser(err) {
+			return APIVersion027, nil
+		}
+		return "", err
+	case !IsUnimplemented(err):
+		return "", err
+	}
+
+	err = c.conn.Invoke(ctx, getRoutesMethod, &emptypb.Empty{}, &emptypb.Empty{})
+	switch {
+	case err == nil:
+		return APIVersion023, nil
+	case IsUnimplemented(err):
+		return APIVersion026, nil
+	default:
+		return "", err
+	}
+}
+
+// isMissingUser reports whether err is the error of listing pre-auth keys
+// without a user. Servers before 0.28 return the database error without a
+// gRPC status code.
+func isMissingUser(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound:
+		return true
+	case codes.Unknown:
+		return strings.Contains(status.Convert(err).Message(), "not found")
+	}
+	return false
+}
+
+// listPreAuthKeysByUser lists the pre-auth keys of every user, as servers
+// before 0.28 require. The user is sent by name before 0.26 and by ID after,
+// in the field the current request no longer has.
+func (c *grpcHeadscaleClient) listPreAuthKeysByUser(
+	ctx context.Context,
+	version string,
+) ([]*headscalev1.PreAuthKey, error) {
+	users, err := c.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = c.ctxWithAuth(ctx)
+	var keys []*headscalev1.PreAuthKey
+	seen := make(map[uint64]bool)
+	for _, user := range users {
+		var field []byte
+		if version == APIVersion023 {
+			field = protowire.AppendTag(field, 1, protowire.BytesType)
+			field = protowire.AppendString(field, user.GetName())
+		} else {
+			field = protowire.AppendTag(field, 1, protowire.VarintType)
+			field = protowire.AppendVarint(field, user.GetId())
+		}
+		req := &headscalev1.ListPreAuthKeysRequest{}
+		req.ProtoReflect().SetUnknown(field)
+
+		resp, err := c.client.ListPreAuthKeys(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("listing pre-auth keys of user %s: %w", user.GetName(), err)
+		}
+		for _, key := range resp.GetPreAuthKeys() {
+			if !seen[key.GetId()] {
+				seen[key.GetId()] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// addLegacyRoutes sets the routes of nodes from GetRoutes, as servers before
+// 0.26 do not report them in nodes. Advertised routes are available, enabled
+// routes approved, and primary or exit routes that are enabled are served.
+func (c *grpcHeadscaleClient) addLegacyRoutes(ctx context.Context, nodes []*headscalev1.Node) error {
+	resp := &emptypb.Empty{}
+	if err := c.conn.Invoke(c.ctxWithAuth(ctx), getRoutesMethod, &emptypb.Empty{}, resp); err != nil {
+		return err
+	}
+	routes, err := parseLegacyRoutes(resp.ProtoReflect().GetUnknown())
+	if err != nil {
+		return fmt.Errorf("parsing routes: %w", err)
+	}
+
+	byID := make(map[uint64]*headscalev1.Node, len(nodes))
+	for _, node := range nodes {
+		byID[node.GetId()] = node
+	}
+	for _, route := range routes {
+		node, ok := byID[route.nodeID]
+		if !ok {
+			continue
+		}
+		if route.advertised {
+			node.AvailableRoutes = append(node.AvailableRoutes, route.prefix)
+		}
+		if route.enabled {
+			node.ApprovedRoutes = append(node.ApprovedRoutes, route.prefix)
+		}
+		if route.enabled && (route.primary || isExitRoute(route.prefix)) {
+			node.SubnetRoutes = append(node.SubnetRoutes, route.prefix)
+		}
+	}
+	return nil
+}
+
+// legacyRoute is a route as listed by GetRoutes.
+type legacyRoute struct {
+	nodeID     uint64
+	prefix     string
+	advertised bool
+	enabled    bool
+	primary    bool
+}
+
+// parseLegacyRoutes reads the routes of a GetRoutesResponse.
+func parseLegacyRoutes(b []byte) ([]legacyRoute, error) {
+	var routes []legacyRoute
+	err := rangeFields(b, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != 1 {
+			return nil
+		}
+		var route legacyRoute
+		err := rangeFields(value, func(num protowire.Number, value []byte, v uint64) error {
+			switch num {
+			case 2:
+				node := &headscalev1.Node{}
+				if err := proto.Unmarshal(value, node); err != nil {
+					return err
+				}
+				route.nodeID = node.GetId()
+			case 3:
+				route.prefix = string(value)
+			case 4:
+				route.advertised = v != 0
+			case 5:
+				route.enabled = v != 0
+			case 6:
+				route.primary = v != 0
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := netip.ParsePrefix(route.prefix); err != nil {
+			return err
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	return routes, err
+}
+
+var errInvalidProto = errors.New("invalid protobuf encoding")
+
+// rangeFields calls fn for every field of the encoded message b, with the
+// content of length delimited fields or the value of varint fields. Other
+// fields are skipped.
+func rangeFields(b []byte, fn func(num protowire.Number, value []byte, v uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidProto
+		}
+		b = b[n:]
+
+		var (
+			value []byte
+			v     uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return errInvalidProto
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return errInvalidProto
+		}
+		b = b[n:]
+
+		if err := fn(num, value, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package headscale
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeHeadscaleServer answers like a Headscale server of the API version.
+// Pre-auth keys are owned by the user with the same ID.
+type fakeHeadscaleServer struct {
+	version string
+	// preAuthKeysErr, when set, is returned by ListPreAuthKeys.
+	preAuthKeysErr error
+}
+
+var (
+	fakeUsers = []*headscalev1.User{
+		{Id: 1, Name: "alice"},
+		{Id: 2, Name: "bob"},
+	}
+	fakePreAuthKeys = []*headscalev1.PreAuthKey{
+		{Id: 1, Key: "alice-key"},
+		{Id: 2, Key: "bob-key"},
+	}
+)
+
+func (s *fakeHeadscaleServer) handle(_ any, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	req := &emptypb.Empty{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	switch method {
+	case "/headscale.v1.HeadscaleService/Health":
+		if s.version == APIVersion023 || s.version == APIVersion026 {
+			break
+		}
+		return stream.SendMsg(&headscalev1.HealthResponse{DatabaseConnectivity: true})
+	case "/headscale.v1.HeadscaleService/ListUsers":
+		return stream.SendMsg(&headscalev1.ListUsersResponse{Users: fakeUsers})
+	case "/headscale.v1.HeadscaleService/ListNodes":
+		return stream.SendMsg(&headscalev1.ListNodesResponse{Nodes: []*headscalev1.Node{
+			{Id: 1, Name: "router"},
+		}})
+	case "/headscale.v1.HeadscaleService/ListPreAuthKeys":
+		if s.preAuthKeysErr != nil {
+			return s.preAuthKeysErr
+		}
+		if s.version == APIVersion028 {
+			return stream.SendMsg(&headscalev1.ListPreAuthKeysResponse{PreAuthKeys: fakePreAuthKeys})
+		}
+		user, err := s.preAuthKeysUser(req.ProtoReflect().GetUnknown())
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(&headscalev1.ListPreAuthKeysResponse{
+			PreAuthKeys: fakePreAuthKeys[user-1 : user],
+		})
+	case getRoutesMethod:
+		if s.version != APIVersion023 {
+			break
+		}
+		return stream.SendMsg(legacyRoutesResponse())
+	}
+	return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+}
+
+// preAuthKeysUser returns the ID of the user of a ListPreAuthKeys request,
+// which is its name before 0.26.
+func (s *fakeHeadscaleServer) preAuthKeysUser(req []byte) (uint64, error) {
+	var user uint64
+	_ = rangeFields(req, func(num protowire.Number, value []byte, v uint64) error {
+		if num != 1 {
+			return nil
+		}
+		if s.version == APIVersion023 {
+			for _, u := range fakeUsers {
+				if u.GetName() == string(value) {
+					user = u.GetId()
+				}
+			}
+		} else {
+			user = v
+		}
+		return nil
+	})
+	if user == 0 {
+		return 0, status.Error(codes.Unknown, "record not found")
+	}
+	return user, nil
+}
+
+// legacyRoutesResponse returns a GetRoutesResponse of a node that is primary
+// for one subnet, waits for approval of another and serves an exit route.
+func legacyRoutesResponse() *emptypb.Empty {
+	node, _ := proto.Marshal(&headscalev1.Node{Id: 1, Name: "router"})
+	var routes []byte
+	for _, route := range []struct {
+		prefix                       string
+		advertised, enabled, primary bool
+	}{
+		{"10.0.0.0/24", true, true, true},
+		{"10.0.1.0/24", true, false, false},
+		{"0.0.0.0/0", true, true, false},
+	} {
+		var b []byte
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, node)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, route.prefix)
+		for num, v := range []bool{route.advertised, route.enabled, route.primary} {
+			b = protowire.AppendTag(b, protowire.Number(num+4), protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+		}
+		routes = protowire.AppendTag(routes, 1, protowire.BytesType)
+		routes = protowire.AppendBytes(routes, b)
+	}
+	resp := &emptypb.Empty{}
+	resp.ProtoReflect().SetUnknown(routes)
+	return resp
+}
+
+func newFakeGRPCClient(t *testing.T, version string) HeadscaleClient {
+	t.Helper()
+	return newFakeGRPCServerClient(t, &fakeHeadscaleServer{version: version})
+}
+
+func newFakeGRPCServerClient(t *testing.T, fake *fakeHeadscaleServer) HeadscaleClient {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(fake.handle))
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return NewGRPCHeadscaleClient(conn, NewAPIKey("test-key", ""))
+}
+
+func TestGRPCHeadscaleClient_Compatibility(t *testing.T) {
+	for _, version := range []string{APIVersion023, APIVersion026, APIVersion027, APIVersion028} {
+		t.Run(version, func(t *testing.T) {
+			client := newFakeGRPCClient(t, version)
+
+			detected, err := client.ServerVersion(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if detected != version {
+				t.Fatalf("detected version %q, expected %q", detected, version)
+			}
+
+			keys, err := client.ListPreAuthKeys(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(keys) != len(fakePreAuthKeys) {
+				t.Fatalf("expected %d pre-auth keys, got %d", len(fakePreAuthKeys), len(keys))
+			}
+
+			nodes, err := client.ListNodes(t.Context())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if version != APIVersion023 {
+				return
+			}
+			node := nodes[0]
+			if !slices.Equal(node.GetAvailableRoutes(), []string{"10.0.0.0/24", "10.0.1.0/24", "0.0.0.0/0"}) ||
+				!slices.Equal(node.GetApprovedRoutes(), []string{"10.0.0.0/24", "0.0.0.0/0"}) ||
+				!slices.Equal(node.GetSubnetRoutes(), []string{"10.0.0.0/24", "0.0.0.0/0"}) {
+				t.Fatalf("unexpected legacy routes: %v", node)
+			}
+		})
+	}
+}
+
+func TestGRPCHeadscaleClient_DetectVersionError(t *testing.T) {
+	client := newFakeGRPCServerClient(t, &fakeHeadscaleServer{
+		version:        APIVersion028,
+		preAuthKeysErr: status.Error(codes.PermissionDenied, "api key lacks permission"),
+	})
+
+	if _, err := client.ServerVersion(t.Context()); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a permission denied error, got %v", err)
+	}
+}
+
+func TestHeadscaleCollector_UnsupportedCollector(t *testing.T) {
+	client := &mockHeadscaleClient{
+		healthErr: status.Error(codes.Unimplemented, "unknown method Health"),
+	}
+
+	ch := make(chan prometheus.Metric, 8)
+	execute(t.Context(), "health", HeadscaleHealthCollector{log: testLogger(t)}, client, ch, testLogger(t))
+	close(ch)
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+
+	err := testutil.CollectAndCompare(&testMetricCollector{metrics: metrics}, strings.NewReader(`
+# HELP headscale_scrape_collector_supported headscale_exporter: Whether the Headscale server implements the RPCs of a collector.
+# TYPE headscale_scrape_collector_supported gauge
+headscale_scrape_collector_supported{collector="health"} 0
+`), "headscale_scrape_collector_supported", "headscale_scrape_collector_success")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
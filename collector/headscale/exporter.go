@@ -0,0 +1,231 @@
+package headscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/fileutil"
+)
+
+const exporterSubsystem = "exporter"
+
+// Headscale 0.28 issues keys as hskey-api-<prefix>-<secret> and lists them as
+// hskey-api-<prefix>-***. Older keys are <prefix>.<secret> and listed as the
+// bare prefix, or as <prefix>*** by 0.28.
+const apiKeyFormatPrefix = "hskey-api-"
+
+var exporterAPIKeyExpiryDesc = newDesc(
+	exporterSubsystem,
+	"api_key_expiry_timestamp_seconds",
+	"Unix timestamp when the API key used by the exporter expires",
+	[]string{"prefix"},
+)
+
+// APIKey is the API key the exporter authenticates with. It is replaced when
+// the key is rotated and, when a file is set, written to it so that restarts
+// use the rotated key.
+type APIKey struct {
+	mtx  sync.RWMutex
+	key  string
+	file string
+
+	// rotateMtx serializes rotations, so that concurrent scrapes create a
+	// single key.
+	rotateMtx sync.Mutex
+}
+
+// NewAPIKey returns the API key key, persisted to file on rotation unless
+// file is empty.
+func NewAPIKey(key, file string) *APIKey {
+	return &APIKey{
+		key:  key,
+		file: file,
+	}
+}
+
+// ReadAPIKeyFile reads an API key from file.
+func ReadAPIKeyFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Key returns the current API key.
+func (k *APIKey) Key() string {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return k.key
+}
+
+// Prefix returns the prefix Headscale identifies the key by.
+func (k *APIKey) Prefix() string {
+	return apiKeyPrefix(k.Key())
+}
+
+func apiKeyPrefix(key string) string {
+	if rest, ok := strings.CutPrefix(key, apiKeyFormatPrefix); ok {
+		prefix, _, _ := strings.Cut(rest, "-")
+		return prefix
+	}
+	prefix, _, _ := strings.Cut(key, ".")
+	return prefix
+}
+
+// Persistent reports whether rotated keys are written to a file.
+func (k *APIKey) Persistent() bool {
+	return k.file != ""
+}
+
+func (k *APIKey) replace(key string) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.file != "" {
+		if err := fileutil.WriteFileAtomic(k.file, []byte(key+"\n")); err != nil {
+			return fmt.Errorf("writing API key file: %w", err)
+		}
+	}
+	k.key = key
+	return nil
+}
+
+// listedAPIKeyPrefix returns the prefix of a key as listed by ListApiKeys.
+func listedAPIKeyPrefix(listed string) string {
+	prefix := strings.TrimPrefix(listed, apiKeyFormatPrefix)
+	prefix = strings.TrimSuffix(prefix, "***")
+	return strings.TrimSuffix(prefix, "-")
+}
+
+// HeadscaleExporterCollector tracks the expiry of the API key used by the
+// exporter, which stops every collector when it expires, and optionally
+// rotates it before.
+type HeadscaleExporterCollector struct {
+	log          *slog.Logger
+	apiKey       *APIKey
+	warnBefore   time.Duration
+	rotateBefore time.Duration
+	lifetime     time.Duration
+}
+
+func init() {
+	registerCollector(exporterSubsystem, NewHeadscaleExporterCollector)
+}
+
+func NewHeadscaleExporterCollector(config collectorConfig) (Collector, error) {
+	if config.APIKey == nil {
+		return nil, nil
+	}
+	if config.APIKeyRotateBefore > 0 {
+		if !config.APIKey.Persistent() {
+			return nil, errors.New("rotating the Headscale API key requires an API key file")
+		}
+		if config.APIKeyLifetime <= config.APIKeyRotateBefore {
+			return nil, fmt.Errorf(
+				"headscale API key lifetime %s must exceed the rotation threshold %s",
+				config.APIKeyLifetime, config.APIKeyRotateBefore,
+			)
+		}
+	}
+
+	return &HeadscaleExporterCollector{
+		log:          config.logger,
+		apiKey:       config.APIKey,
+		warnBefore:   config.APIKeyWarnBefore,
+		rotateBefore: config.APIKeyRotateBefore,
+		lifetime:     config.APIKeyLifetime,
+	}, nil
+}
+
+func (c HeadscaleExporterCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting exporter API key metrics")
+
+	apiKeys, err := client.ListAPIKeys(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale API keys", "error", err)
+		return err
+	}
+
+	prefix := c.apiKey.Prefix()
+	var expiration time.Time
+	found := false
+	for _, key := range apiKeys {
+		if listedAPIKeyPrefix(key.GetPrefix()) != prefix {
+			continue
+		}
+		found = true
+		if ts := key.GetExpiration(); ts != nil {
+			expiration = ts.AsTime()
+		}
+		break
+	}
+	if !found {
+		return fmt.Errorf("API key with prefix %q of the exporter is not listed by Headscale", prefix)
+	}
+	if expiration.IsZero() {
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(exporterAPIKeyExpiryDesc, prometheus.GaugeValue,
+		float64(expiration.Unix()), prefix,
+	)
+
+	remaining := time.Until(expiration)
+	if c.rotateBefore > 0 && remaining < c.rotateBefore {
+		return c.rotate(ctx, client, prefix)
+	}
+	if remaining < c.warnBefore {
+		c.log.WarnContext(ctx, "Headscale API key of the exporter expires soon",
+			"prefix", prefix,
+			"expiration", expiration,
+		)
+	}
+
+	return nil
+}
+
+// rotate creates a new API key and switches the exporter to it. The old key
+// is left to expire, so other exporters sharing it keep working.
+func (c HeadscaleExporterCollector) rotate(ctx context.Context, client HeadscaleClient, prefix string) error {
+	c.apiKey.rotateMtx.Lock()
+	defer c.apiKey.rotateMtx.Unlock()
+
+	// A concurrent scrape rotated the key while this one waited.
+	if c.apiKey.Prefix() != prefix {
+		return nil
+	}
+
+	key, err := client.CreateAPIKey(ctx, time.Now().Add(c.lifetime))
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error creating Headscale API key", "error", err)
+		return err
+	}
+	if err := c.apiKey.replace(key); err != nil {
+		c.log.ErrorContext(ctx, "Error replacing Headscale API key", "error", err)
+		// The new key is not stored anywhere, so it is expired rather than
+		// left valid for its lifetime.
+		if err := client.ExpireAPIKey(ctx, apiKeyPrefix(key)); err != nil {
+			c.log.ErrorContext(ctx, "Error expiring unused Headscale API key", "error", err)
+		}
+		return err
+	}
+
+	c.log.InfoContext(ctx, "Rotated Headscale API key of the exporter",
+		"old_prefix", prefix,
+		"new_prefix", c.apiKey.Prefix(),
+	)
+	return nil
+}
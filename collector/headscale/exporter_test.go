@@ -0,0 +1,202 @@
+package headscale
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAPIKey_Prefix(t *testing.T) {
+	tests := []struct {
+		key    string
+		listed string
+	}{
+		{key: "hskey-api-abcdefghijkl-secretsecret", listed: "hskey-api-abcdefghijkl-***"},
+		{key: "abcdefg.secretsecret", listed: "abcdefg***"},
+		{key: "abcdefg.secretsecret", listed: "abcdefg"},
+	}
+
+	for _, tt := range tests {
+		prefix := NewAPIKey(tt.key, "").Prefix()
+		if listed := listedAPIKeyPrefix(tt.listed); listed != prefix {
+			t.Errorf("prefix %q of key %q does not match listed prefix %q", prefix, tt.key, listed)
+		}
+	}
+}
+
+func TestHeadscaleExporterCollector_Update(t *testing.T) {
+	expiration := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	client := &mockHeadscaleClient{
+		apiKeys: []*headscalev1.ApiKey{
+			{Id: 1, Prefix: "hskey-api-otherprefix0-***", Expiration: timestamppb.New(time.Unix(1_700_000_000, 0))},
+			{Id: 2, Prefix: "hskey-api-abcdefghijkl-***", Expiration: timestamppb.New(expiration)},
+		},
+	}
+
+	collector, err := NewHeadscaleExporterCollector(collectorConfig{
+		logger: testLogger(t),
+		Config: Config{
+			APIKey:           NewAPIKey("hskey-api-abcdefghijkl-secret", ""),
+			APIKeyWarnBefore: 14 * 24 * time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create exporter collector: %v", err)
+	}
+
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_exporter_api_key_expiry_timestamp_seconds Unix timestamp when the API key used by the exporter expires
+# TYPE headscale_exporter_api_key_expiry_timestamp_seconds gauge
+headscale_exporter_api_key_expiry_timestamp_seconds{prefix="abcdefghijkl"} ` +
+		strconv.FormatFloat(float64(expiration.Unix()), 'g', -1, 64) + `
+`
+	gatherMetrics(t, metrics, expected)
+	if len(client.createdKeys) != 0 {
+		t.Fatalf("expected no key rotation, got %d", len(client.createdKeys))
+	}
+}
+
+func TestHeadscaleExporterCollector_Rotate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "api-key")
+	apiKey := NewAPIKey("abcdefg.secret", file)
+	client := &mockHeadscaleClient{
+		apiKeys: []*headscalev1.ApiKey{
+			{Id: 1, Prefix: "abcdefg", Expiration: timestamppb.New(time.Now().Add(time.Hour))},
+		},
+	}
+
+	collector, err := NewHeadscaleExporterCollector(collectorConfig{
+		logger: testLogger(t),
+		Config: Config{
+			APIKey:             apiKey,
+			APIKeyRotateBefore: 7 * 24 * time.Hour,
+			APIKeyLifetime:     90 * 24 * time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create exporter collector: %v", err)
+	}
+
+	collectFromCollector(t, collector, client)
+
+	if len(client.createdKeys) != 1 {
+		t.Fatalf("expected one key to be created, got %d", len(client.createdKeys))
+	}
+	if until := time.Until(client.createdKeys[0]); until < 89*24*time.Hour {
+		t.Errorf("expected the new key to be valid for its lifetime, expires in %s", until)
+	}
+	if apiKey.Key() != "hskey-api-newprefix123-secret" {
+		t.Errorf("expected the exporter to use the new key, got %q", apiKey.Key())
+	}
+	stored, err := ReadAPIKeyFile(file)
+	if err != nil {
+		t.Fatalf("failed to read API key file: %v", err)
+	}
+	if stored != apiKey.Key() {
+		t.Errorf("expected the new key to be written to the file, got %q", stored)
+	}
+}
+
+func TestHeadscaleExporterCollector_RotateOnce(t *testing.T) {
+	apiKey := NewAPIKey("abcdefg.secret", filepath.Join(t.TempDir(), "api-key"))
+	client := &mockHeadscaleClient{}
+	collector := HeadscaleExporterCollector{
+		log:          testLogger(t),
+		apiKey:       apiKey,
+		rotateBefore: time.Hour,
+		lifetime:     2 * time.Hour,
+	}
+
+	// Both scrapes saw the old key, the second one waited for the first to
+	// rotate it.
+	for range 2 {
+		if err := collector.rotate(t.Context(), client, "abcdefg"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(client.createdKeys) != 1 {
+		t.Fatalf("expected one key to be created, got %d", len(client.createdKeys))
+	}
+}
+
+func TestHeadscaleExporterCollector_RotateWriteError(t *testing.T) {
+	apiKey := NewAPIKey("abcdefg.secret", filepath.Join(t.TempDir(), "missing", "api-key"))
+	client := &mockHeadscaleClient{}
+	collector := HeadscaleExporterCollector{
+		log:          testLogger(t),
+		apiKey:       apiKey,
+		rotateBefore: time.Hour,
+		lifetime:     2 * time.Hour,
+	}
+
+	if err := collector.rotate(t.Context(), client, "abcdefg"); err == nil {
+		t.Fatal("expected error when the API key file cannot be written")
+	}
+	if apiKey.Key() != "abcdefg.secret" {
+		t.Errorf("expected the exporter to keep the old key, got %q", apiKey.Key())
+	}
+	if len(client.expiredKeys) != 1 || client.expiredKeys[0] != "newprefix123" {
+		t.Errorf("expected the new key to be expired, got %v", client.expiredKeys)
+	}
+}
+
+func TestHeadscaleExporterCollector_NotListed(t *testing.T) {
+	collector, err := NewHeadscaleExporterCollector(collectorConfig{
+		logger: testLogger(t),
+		Config: Config{APIKey: NewAPIKey("abcdefg.secret", "")},
+	})
+	if err != nil {
+		t.Fatalf("failed to create exporter collector: %v", err)
+	}
+
+	ch := make(chan prometheus.Metric, 4)
+	if err := collector.Update(t.Context(), &mockHeadscaleClient{}, ch); err == nil {
+		t.Fatal("expected error for an API key that is not listed")
+	}
+}
+
+func TestNewHeadscaleExporterCollector(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		expectNil   bool
+		expectError string
+	}{
+		{name: "disabled without API key", expectNil: true},
+		{
+			name:        "rotation without file",
+			config:      Config{APIKey: NewAPIKey("abcdefg.secret", ""), APIKeyRotateBefore: time.Hour, APIKeyLifetime: 2 * time.Hour},
+			expectError: "requires an API key file",
+		},
+		{
+			name:        "lifetime below rotation threshold",
+			config:      Config{APIKey: NewAPIKey("abcdefg.secret", "key"), APIKeyRotateBefore: time.Hour, APIKeyLifetime: time.Hour},
+			expectError: "must exceed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewHeadscaleExporterCollector(collectorConfig{logger: testLogger(t), Config: tt.config})
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (collector == nil) != tt.expectNil {
+				t.Fatalf("collector = %v, expected nil %v", collector, tt.expectNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package headscale
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const inventorySubsystem = "inventory"
+
+var (
+	inventoryUserNodesDesc = newDesc(
+		inventorySubsystem,
+		"user_nodes",
+		"Number of nodes owned by the user, nodes owned by tags are not attributed to a user",
+		[]string{"user_id", "name"},
+	)
+	inventoryTaggedNodesDesc = newDesc(
+		inventorySubsystem,
+		"tagged_nodes",
+		"Number of nodes owned by tags",
+		nil,
+	)
+	inventoryOrphanedNodeDesc = newDesc(
+		inventorySubsystem,
+		"orphaned_node",
+		"Node without tags whose user is missing or no longer exists",
+		[]string{"id", "name", "user_id", "user"},
+	)
+	inventoryOrphanedNodesDesc = newDesc(
+		inventorySubsystem,
+		"orphaned_nodes",
+		"Number of nodes without tags whose user is missing or no longer exists",
+		nil,
+	)
+	inventoryUserWithoutNodesDesc = newDesc(
+		inventorySubsystem,
+		"user_without_nodes",
+		"User that owns no nodes",
+		[]string{"user_id", "name"},
+	)
+)
+
+type HeadscaleInventoryCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(inventorySubsystem, NewHeadscaleInventoryCollector)
+}
+
+func NewHeadscaleInventoryCollector(config collectorConfig) (Collector, error) {
+	return &HeadscaleInventoryCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c HeadscaleInventoryCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting inventory metrics")
+
+	users, err := client.ListUsers(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale users", "error", err)
+		return err
+	}
+
+	nodes, err := client.ListNodes(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale nodes", "error", err)
+		return err
+	}
+
+	userNodes := make(map[uint64]int, len(users))
+	for _, user := range users {
+		userNodes[user.GetId()] = 0
+	}
+
+	var tagged, orphaned int
+	for _, node := range nodes {
+		if len(node.GetTags()) > 0 {
+			tagged++
+			continue
+		}
+
+		user := node.GetUser()
+		if _, ok := userNodes[user.GetId()]; user != nil && ok {
+			userNodes[user.GetId()]++
+			continue
+		}
+
+		orphaned++
+		userID := ""
+		if user != nil {
+			userID = formatUint(user.GetId())
+		}
+		ch <- prometheus.MustNewConstMetric(inventoryOrphanedNodeDesc, prometheus.GaugeValue, 1,
+			formatUint(node.GetId()), node.GetName(), userID, user.GetName(),
+		)
+	}
+
+	for _, user := range users {
+		userID := formatUint(user.GetId())
+		count := userNodes[user.GetId()]
+		ch <- prometheus.MustNewConstMetric(inventoryUserNodesDesc, prometheus.GaugeValue,
+			float64(count), userID, user.GetName(),
+		)
+
+		if count == 0 {
+			ch <- prometheus.MustNewConstMetric(inventoryUserWithoutNodesDesc, prometheus.GaugeValue, 1,
+				userID, user.GetName(),
+			)
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(inventoryTaggedNodesDesc, prometheus.GaugeValue, float64(tagged))
+	ch <- prometheus.MustNewConstMetric(inventoryOrphanedNodesDesc, prometheus.GaugeValue, float64(orphaned))
+
+	return nil
+}
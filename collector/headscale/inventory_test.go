@@ -0,0 +1,67 @@
+package headscale
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHeadscaleInventoryCollector_Update(t *testing.T) {
+	collector, err := NewHeadscaleInventoryCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create inventory collector: %v", err)
+	}
+
+	alice := &headscalev1.User{Id: 1, Name: "alice"}
+	bob := &headscalev1.User{Id: 2, Name: "bob"}
+	deleted := &headscalev1.User{Id: 9, Name: "mallory"}
+
+	client := &mockHeadscaleClient{
+		users: []*headscalev1.User{alice, bob},
+		nodes: []*headscalev1.Node{
+			{Id: 10, Name: "laptop", User: alice},
+			{Id: 11, Name: "phone", User: alice},
+			{Id: 12, Name: "router", User: bob, Tags: []string{"tag:router"}},
+			{Id: 13, Name: "old-server", User: deleted},
+			{Id: 14, Name: "lost"},
+		},
+	}
+
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_inventory_orphaned_node Node without tags whose user is missing or no longer exists
+# TYPE headscale_inventory_orphaned_node gauge
+headscale_inventory_orphaned_node{id="13",name="old-server",user="mallory",user_id="9"} 1
+headscale_inventory_orphaned_node{id="14",name="lost",user="",user_id=""} 1
+# HELP headscale_inventory_orphaned_nodes Number of nodes without tags whose user is missing or no longer exists
+# TYPE headscale_inventory_orphaned_nodes gauge
+headscale_inventory_orphaned_nodes 2
+# HELP headscale_inventory_tagged_nodes Number of nodes owned by tags
+# TYPE headscale_inventory_tagged_nodes gauge
+headscale_inventory_tagged_nodes 1
+# HELP headscale_inventory_user_nodes Number of nodes owned by the user, nodes owned by tags are not attributed to a user
+# TYPE headscale_inventory_user_nodes gauge
+headscale_inventory_user_nodes{name="alice",user_id="1"} 2
+headscale_inventory_user_nodes{name="bob",user_id="2"} 0
+# HELP headscale_inventory_user_without_nodes User that owns no nodes
+# TYPE headscale_inventory_user_without_nodes gauge
+headscale_inventory_user_without_nodes{name="bob",user_id="2"} 1
+`
+	gatherMetrics(t, metrics, expected)
+}
+
+func TestHeadscaleInventoryCollector_Update_Error(t *testing.T) {
+	collector, err := NewHeadscaleInventoryCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create inventory collector: %v", err)
+	}
+
+	client := &mockHeadscaleClient{listNodesErr: errors.New("boom")}
+	ch := make(chan prometheus.Metric, 1)
+	if err := collector.Update(context.Background(), client, ch); err == nil {
+		t.Fatal("expected error when listing nodes fails")
+	}
+}
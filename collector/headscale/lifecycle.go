@@ -0,0 +1,92 @@
+package headscale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/lifecycle"
+)
+
+const lifecycleSubsystem = "lifecycle"
+
+var (
+	lifecycleAddedDesc = newDesc(
+		"",
+		"nodes_added_total",
+		"Number of nodes that were registered",
+		nil,
+	)
+	lifecycleRemovedDesc = newDesc(
+		"",
+		"nodes_removed_total",
+		"Number of nodes that were removed",
+		nil,
+	)
+	lifecycleOnlineTransitionsDesc = newDesc(
+		"",
+		"node_online_transitions_total",
+		"Number of times nodes came online or went offline",
+		[]string{"direction"},
+	)
+)
+
+type HeadscaleLifecycleCollector struct {
+	log     *slog.Logger
+	tracker *lifecycle.Tracker
+}
+
+func init() {
+	registerCollector(lifecycleSubsystem, NewHeadscaleLifecycleCollector)
+}
+
+func NewHeadscaleLifecycleCollector(config collectorConfig) (Collector, error) {
+	tracker, err := lifecycle.NewTracker(config.LifecycleStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lifecycle state: %w", err)
+	}
+
+	return &HeadscaleLifecycleCollector{
+		log:     config.logger,
+		tracker: tracker,
+	}, nil
+}
+
+func (c HeadscaleLifecycleCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting lifecycle metrics")
+
+	nodes, err := client.ListNodes(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale nodes", "error", err)
+		return err
+	}
+
+	// Headscale nodes have no authorization state, only online transitions are
+	// tracked.
+	current := make(map[string]lifecycle.State, len(nodes))
+	for _, node := range nodes {
+		current[formatUint(node.GetId())] = lifecycle.State{Online: node.GetOnline()}
+	}
+
+	counts, err := c.tracker.Observe(current)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error saving lifecycle state", "error", err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(lifecycleAddedDesc, prometheus.CounterValue, counts.Added)
+	ch <- prometheus.MustNewConstMetric(lifecycleRemovedDesc, prometheus.CounterValue, counts.Removed)
+	ch <- prometheus.MustNewConstMetric(lifecycleOnlineTransitionsDesc, prometheus.CounterValue,
+		counts.WentOnline, "online",
+	)
+	ch <- prometheus.MustNewConstMetric(lifecycleOnlineTransitionsDesc, prometheus.CounterValue,
+		counts.WentOffline, "offline",
+	)
+
+	return nil
+}
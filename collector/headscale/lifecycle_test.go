@@ -0,0 +1,50 @@
+package headscale
+
+import (
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHeadscaleLifecycleCollector_Update(t *testing.T) {
+	collector, err := NewHeadscaleLifecycleCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create lifecycle collector: %v", err)
+	}
+
+	scrapes := [][]*headscalev1.Node{
+		{
+			{Id: 1, Online: true},
+			{Id: 2, Online: false},
+		},
+		{
+			{Id: 1, Online: false},
+			{Id: 2, Online: true},
+			{Id: 3, Online: true},
+		},
+		{
+			{Id: 2, Online: true},
+			{Id: 3, Online: false},
+		},
+	}
+
+	var metrics []prometheus.Metric
+	for _, nodes := range scrapes {
+		metrics = collectFromCollector(t, collector, &mockHeadscaleClient{nodes: nodes})
+	}
+
+	expected := `
+# HELP headscale_node_online_transitions_total Number of times nodes came online or went offline
+# TYPE headscale_node_online_transitions_total counter
+headscale_node_online_transitions_total{direction="offline"} 2
+headscale_node_online_transitions_total{direction="online"} 1
+# HELP headscale_nodes_added_total Number of nodes that were registered
+# TYPE headscale_nodes_added_total counter
+headscale_nodes_added_total 1
+# HELP headscale_nodes_removed_total Number of nodes that were removed
+# TYPE headscale_nodes_removed_total counter
+headscale_nodes_removed_total 1
+`
+	gatherMetrics(t, metrics, expected)
+}
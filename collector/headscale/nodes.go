@@ -3,10 +3,14 @@ package headscale
 import (
 	"context"
 	"log/slog"
+	"net/netip"
+	"slices"
 
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/adinhodovic/tailscale-exporter/collector/iputil"
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
 )
 
 const nodesSubsystem = "nodes"
@@ -72,6 +76,18 @@ var (
 		"Number of subnet routes advertised by the node",
 		[]string{"id", "name", "user"},
 	)
+	nodesRouteDesc = newDesc(
+		nodesSubsystem,
+		"route",
+		"Route of the node by state: available (announced, not approved), approved (not serving) or serving",
+		[]string{"id", "name", "user", "prefix", "state", "exit"},
+	)
+	nodesRoutePrimaryDesc = newDesc(
+		nodesSubsystem,
+		"route_primary",
+		"Whether the node is the elected primary for an approved and announced subnet route",
+		[]string{"id", "name", "user", "prefix"},
+	)
 	nodesTagsDesc = newDesc(
 		nodesSubsystem,
 		"tags",
@@ -81,7 +97,8 @@ var (
 )
 
 type HeadscaleNodesCollector struct {
-	log *slog.Logger
+	log     *slog.Logger
+	sdStore *sd.Store
 }
 
 func init() {
@@ -90,7 +107,8 @@ func init() {
 
 func NewHeadscaleNodesCollector(config collectorConfig) (Collector, error) {
 	return &HeadscaleNodesCollector{
-		log: config.logger,
+		log:     config.logger,
+		sdStore: config.SDStore,
 	}, nil
 }
 
@@ -107,6 +125,10 @@ func (c HeadscaleNodesCollector) Update(
 		return err
 	}
 
+	if c.sdStore != nil {
+		c.sdStore.Set(namespace, sdNodes(nodes))
+	}
+
 	for _, node := range nodes {
 		nodeID := formatUint(node.GetId())
 		userName := ""
@@ -164,6 +186,8 @@ func (c HeadscaleNodesCollector) Update(
 			nodeID, node.GetName(), userName,
 		)
 
+		c.collectRoutes(ch, node, nodeID, userName)
+
 		ch <- prometheus.MustNewConstMetric(nodesTagsDesc, prometheus.GaugeValue, float64(len(node.GetTags())),
 			nodeID, node.GetName(), userName,
 		)
@@ -171,3 +195,61 @@ func (c HeadscaleNodesCollector) Update(
 
 	return nil
 }
+
+const (
+	routeStateAvailable = "available"
+	routeStateApproved  = "approved"
+	routeStateServing   = "serving"
+)
+
+// collectRoutes sends one series per prefix of the node. Headscale lists the
+// primary subnet routes and the approved exit routes of a node as its subnet
+// routes, so a subnet route that is approved and announced but not listed lost
+// the primary election to another node.
+func (c HeadscaleNodesCollector) collectRoutes(
+	ch chan<- prometheus.Metric,
+	node *headscalev1.Node,
+	nodeID, userName string,
+) {
+	approved := node.GetApprovedRoutes()
+	available := node.GetAvailableRoutes()
+	serving := node.GetSubnetRoutes()
+
+	var prefixes []string
+	for _, routes := range [][]string{available, approved, serving} {
+		for _, prefix := range routes {
+			if !slices.Contains(prefixes, prefix) {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+
+	for _, prefix := range prefixes {
+		state := routeStateAvailable
+		switch {
+		case slices.Contains(serving, prefix):
+			state = routeStateServing
+		case slices.Contains(approved, prefix):
+			state = routeStateApproved
+		}
+		exit := isExitRoute(prefix)
+
+		ch <- prometheus.MustNewConstMetric(nodesRouteDesc, prometheus.GaugeValue, 1,
+			nodeID, node.GetName(), userName, prefix, state, formatBoolLabel(exit),
+		)
+
+		if !exit && slices.Contains(approved, prefix) && slices.Contains(available, prefix) {
+			ch <- prometheus.MustNewConstMetric(nodesRoutePrimaryDesc, prometheus.GaugeValue,
+				boolAsFloat(state == routeStateServing),
+				nodeID, node.GetName(), userName, prefix,
+			)
+		}
+	}
+}
+
+// isExitRoute reports whether prefix is one of the default routes a node
+// announces to act as an exit node.
+func isExitRoute(prefix string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	return err == nil && p.Bits() == 0
+}
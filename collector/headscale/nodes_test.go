@@ -1,10 +1,12 @@
 package headscale
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -66,9 +68,67 @@ headscale_nodes_available_routes{id="1",name="node-one",user="alice"} 1
 # HELP headscale_nodes_subnet_routes Number of subnet routes advertised by the node
 # TYPE headscale_nodes_subnet_routes gauge
 headscale_nodes_subnet_routes{id="1",name="node-one",user="alice"} 1
+# HELP headscale_nodes_route Route of the node by state: available (announced, not approved), approved (not serving) or serving
+# TYPE headscale_nodes_route gauge
+headscale_nodes_route{exit="false",id="1",name="node-one",prefix="10.0.0.0/24",state="approved",user="alice"} 1
+headscale_nodes_route{exit="false",id="1",name="node-one",prefix="10.0.1.0/24",state="approved",user="alice"} 1
+headscale_nodes_route{exit="true",id="1",name="node-one",prefix="0.0.0.0/0",state="serving",user="alice"} 1
+# HELP headscale_nodes_route_primary Whether the node is the elected primary for an approved and announced subnet route
+# TYPE headscale_nodes_route_primary gauge
+headscale_nodes_route_primary{id="1",name="node-one",prefix="10.0.0.0/24",user="alice"} 0
 # HELP headscale_nodes_tags Number of tags applied to the node
 # TYPE headscale_nodes_tags gauge
 headscale_nodes_tags{id="1",name="node-one",user="alice"} 3
 `
 	gatherMetrics(t, metrics, expected)
 }
+
+func TestHeadscaleNodesCollector_Routes(t *testing.T) {
+	collector, err := NewHeadscaleNodesCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create nodes collector: %v", err)
+	}
+
+	// Both routers announce and are approved for 10.0.0.0/24, router-a won
+	// the primary election. router-b also waits for approval of a new subnet
+	// and an exit node.
+	client := &mockHeadscaleClient{
+		nodes: []*headscalev1.Node{
+			{
+				Id:              1,
+				Name:            "router-a",
+				User:            &headscalev1.User{Id: 1, Name: "alice"},
+				ApprovedRoutes:  []string{"10.0.0.0/24", "0.0.0.0/0", "::/0"},
+				AvailableRoutes: []string{"10.0.0.0/24", "0.0.0.0/0", "::/0"},
+				SubnetRoutes:    []string{"10.0.0.0/24", "0.0.0.0/0", "::/0"},
+			},
+			{
+				Id:              2,
+				Name:            "router-b",
+				User:            &headscalev1.User{Id: 1, Name: "alice"},
+				ApprovedRoutes:  []string{"10.0.0.0/24"},
+				AvailableRoutes: []string{"10.0.0.0/24", "10.0.2.0/24", "0.0.0.0/0", "::/0"},
+			},
+		},
+	}
+
+	metrics := collectFromCollector(t, collector, client)
+	err = testutil.CollectAndCompare(&testMetricCollector{metrics: metrics}, strings.NewReader(`
+# HELP headscale_nodes_route Route of the node by state: available (announced, not approved), approved (not serving) or serving
+# TYPE headscale_nodes_route gauge
+headscale_nodes_route{exit="false",id="1",name="router-a",prefix="10.0.0.0/24",state="serving",user="alice"} 1
+headscale_nodes_route{exit="true",id="1",name="router-a",prefix="0.0.0.0/0",state="serving",user="alice"} 1
+headscale_nodes_route{exit="true",id="1",name="router-a",prefix="::/0",state="serving",user="alice"} 1
+headscale_nodes_route{exit="false",id="2",name="router-b",prefix="10.0.0.0/24",state="approved",user="alice"} 1
+headscale_nodes_route{exit="false",id="2",name="router-b",prefix="10.0.2.0/24",state="available",user="alice"} 1
+headscale_nodes_route{exit="true",id="2",name="router-b",prefix="0.0.0.0/0",state="available",user="alice"} 1
+headscale_nodes_route{exit="true",id="2",name="router-b",prefix="::/0",state="available",user="alice"} 1
+# HELP headscale_nodes_route_primary Whether the node is the elected primary for an approved and announced subnet route
+# TYPE headscale_nodes_route_primary gauge
+headscale_nodes_route_primary{id="1",name="router-a",prefix="10.0.0.0/24",user="alice"} 1
+headscale_nodes_route_primary{id="2",name="router-b",prefix="10.0.0.0/24",user="alice"} 0
+`), "headscale_nodes_route", "headscale_nodes_route_primary")
+	if err != nil {
+		t.Fatalf("metrics mismatch: %v", err)
+	}
+}
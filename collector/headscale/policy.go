@@ -0,0 +1,148 @@
+package headscale
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tailscale/hujson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const policySubsystem = "policy"
+
+var (
+	policyInfoDesc = newDesc(
+		policySubsystem,
+		"info",
+		"Policy metadata with the SHA-256 hash of its content",
+		[]string{"hash"},
+	)
+	policyUpdatedDesc = newDesc(
+		policySubsystem,
+		"updated_timestamp",
+		"Unix timestamp when the policy was last updated, only reported in database policy mode",
+		nil,
+	)
+	policyEntriesDesc = newDesc(
+		policySubsystem,
+		"entries",
+		"Number of entries per policy section",
+		[]string{"section"},
+	)
+)
+
+// policyDocument holds the sections of a Headscale policy that are counted.
+// The entries themselves are not interpreted.
+type policyDocument struct {
+	ACLs          []json.RawMessage          `json:"acls"`
+	Groups        map[string]json.RawMessage `json:"groups"`
+	TagOwners     map[string]json.RawMessage `json:"tagOwners"`
+	Hosts         map[string]json.RawMessage `json:"hosts"`
+	AutoApprovers struct {
+		Routes   map[string]json.RawMessage `json:"routes"`
+		ExitNode []json.RawMessage          `json:"exitNode"`
+	} `json:"autoApprovers"`
+	SSH []json.RawMessage `json:"ssh"`
+}
+
+type HeadscalePolicyCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(policySubsystem, NewHeadscalePolicyCollector)
+}
+
+func NewHeadscalePolicyCollector(config collectorConfig) (Collector, error) {
+	return &HeadscalePolicyCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c HeadscalePolicyCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting policy metrics")
+
+	resp, err := client.GetPolicy(ctx)
+	if isPolicyNotFound(err) {
+		// In database policy mode no policy is stored until one is set, and
+		// every section is empty.
+		c.log.DebugContext(ctx, "No Headscale policy set")
+		writePolicyEntries(ch, &policyDocument{})
+		return nil
+	}
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale policy", "error", err)
+		return err
+	}
+
+	policy, err := parsePolicy(resp.GetPolicy())
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error parsing Headscale policy", "error", err)
+		return err
+	}
+
+	// The hash covers the policy as stored, so comment and formatting
+	// changes are visible as well.
+	sum := sha256.Sum256([]byte(resp.GetPolicy()))
+	ch <- prometheus.MustNewConstMetric(policyInfoDesc, prometheus.GaugeValue, 1, hex.EncodeToString(sum[:]))
+
+	// Policies read from a file have no update time.
+	if ts := resp.GetUpdatedAt(); ts != nil {
+		ch <- prometheus.MustNewConstMetric(policyUpdatedDesc, prometheus.GaugeValue, timestampToFloat(ts))
+	}
+
+	writePolicyEntries(ch, policy)
+	return nil
+}
+
+// isPolicyNotFound reports whether err is the error of GetPolicy when no
+// policy is stored. Headscale returns it without a gRPC status code.
+func isPolicyNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	return status.Code(err) == codes.NotFound ||
+		strings.Contains(status.Convert(err).Message(), "policy not found")
+}
+
+func writePolicyEntries(ch chan<- prometheus.Metric, policy *policyDocument) {
+	autoApprovers := len(policy.AutoApprovers.Routes)
+	if len(policy.AutoApprovers.ExitNode) > 0 {
+		autoApprovers++
+	}
+	entries := map[string]int{
+		"acls":           len(policy.ACLs),
+		"groups":         len(policy.Groups),
+		"tag_owners":     len(policy.TagOwners),
+		"hosts":          len(policy.Hosts),
+		"auto_approvers": autoApprovers,
+		"ssh":            len(policy.SSH),
+	}
+	for section, count := range entries {
+		ch <- prometheus.MustNewConstMetric(policyEntriesDesc, prometheus.GaugeValue, float64(count), section)
+	}
+}
+
+// parsePolicy reads a policy in HuJSON, the format Headscale stores it in.
+func parsePolicy(data string) (*policyDocument, error) {
+	standard, err := hujson.Standardize([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy HuJSON: %w", err)
+	}
+	policy := &policyDocument{}
+	if err := json.Unmarshal(standard, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return policy, nil
+}
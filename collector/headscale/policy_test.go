@@ -0,0 +1,117 @@
+package headscale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testPolicy = `{
+	// Admins can reach everything.
+	"groups": {
+		"group:admin": ["alice@"],
+		"group:dev": ["bob@", "carol@"],
+	},
+	"tagOwners": {
+		"tag:server": ["group:admin"],
+	},
+	"hosts": {
+		"db": "100.64.0.10/32",
+	},
+	"acls": [
+		{"action": "accept", "src": ["group:admin"], "dst": ["*:*"]},
+		{"action": "accept", "src": ["group:dev"], "dst": ["db:5432"]},
+	],
+	"autoApprovers": {
+		"routes": {
+			"10.0.0.0/8": ["tag:server"],
+			"192.168.0.0/16": ["group:admin"],
+		},
+		"exitNode": ["tag:server"],
+	},
+	"ssh": [
+		{"action": "accept", "src": ["group:admin"], "dst": ["tag:server"], "users": ["root"]},
+	],
+}`
+
+func TestHeadscalePolicyCollector_Update(t *testing.T) {
+	collector, err := NewHeadscalePolicyCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create policy collector: %v", err)
+	}
+
+	client := &mockHeadscaleClient{
+		policyResp: &headscalev1.GetPolicyResponse{
+			Policy:    testPolicy,
+			UpdatedAt: timestamppb.New(time.Unix(1700000000, 0)),
+		},
+	}
+
+	sum := sha256.Sum256([]byte(testPolicy))
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_policy_entries Number of entries per policy section
+# TYPE headscale_policy_entries gauge
+headscale_policy_entries{section="acls"} 2
+headscale_policy_entries{section="auto_approvers"} 3
+headscale_policy_entries{section="groups"} 2
+headscale_policy_entries{section="hosts"} 1
+headscale_policy_entries{section="ssh"} 1
+headscale_policy_entries{section="tag_owners"} 1
+# HELP headscale_policy_info Policy metadata with the SHA-256 hash of its content
+# TYPE headscale_policy_info gauge
+headscale_policy_info{hash="` + hex.EncodeToString(sum[:]) + `"} 1
+# HELP headscale_policy_updated_timestamp Unix timestamp when the policy was last updated, only reported in database policy mode
+# TYPE headscale_policy_updated_timestamp gauge
+headscale_policy_updated_timestamp 1.7e+09
+`
+	gatherMetrics(t, metrics, expected)
+}
+
+func TestHeadscalePolicyCollector_InvalidPolicy(t *testing.T) {
+	collector, err := NewHeadscalePolicyCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create policy collector: %v", err)
+	}
+
+	client := &mockHeadscaleClient{
+		policyResp: &headscalev1.GetPolicyResponse{Policy: `{"acls": [`},
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+	if err := collector.Update(t.Context(), client, ch); err == nil {
+		t.Fatal("expected error for invalid policy")
+	}
+}
+
+func TestHeadscalePolicyCollector_NoPolicy(t *testing.T) {
+	collector, err := NewHeadscalePolicyCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create policy collector: %v", err)
+	}
+
+	// Headscale wraps the database error without a status code.
+	client := &mockHeadscaleClient{
+		policyErr: status.Error(codes.Unknown, "loading ACL from database: acl policy not found"),
+	}
+
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_policy_entries Number of entries per policy section
+# TYPE headscale_policy_entries gauge
+headscale_policy_entries{section="acls"} 0
+headscale_policy_entries{section="auto_approvers"} 0
+headscale_policy_entries{section="groups"} 0
+headscale_policy_entries{section="hosts"} 0
+headscale_policy_entries{section="ssh"} 0
+headscale_policy_entries{section="tag_owners"} 0
+`
+	gatherMetrics(t, metrics, expected)
+}
@@ -0,0 +1,37 @@
+package headscale
+
+import (
+	"context"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/iputil"
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+// ServiceDiscoveryNodes lists the Headscale nodes as service discovery targets.
+func (h *HeadscaleCollector) ServiceDiscoveryNodes(ctx context.Context) ([]sd.Device, error) {
+	nodes, err := h.client.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sdNodes(nodes), nil
+}
+
+func sdNodes(nodes []*headscalev1.Node) []sd.Device {
+	result := make([]sd.Device, 0, len(nodes))
+	for _, node := range nodes {
+		ipv4, ipv6 := iputil.SplitIPs(node.GetIpAddresses())
+		result = append(result, sd.Device{
+			// The given name is the node's MagicDNS name.
+			Machine:  node.GetGivenName(),
+			Hostname: node.GetName(),
+			User:     node.GetUser().GetName(),
+			Tags:     node.GetTags(),
+			Online:   node.GetOnline(),
+			IPv4:     ipv4,
+			IPv6:     ipv6,
+		})
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package headscale
+
+import (
+	"reflect"
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+func TestSDNodes(t *testing.T) {
+	nodes := []*headscalev1.Node{
+		{
+			Name:        "alice-laptop",
+			GivenName:   "laptop",
+			User:        &headscalev1.User{Name: "alice"},
+			Tags:        []string{"tag:dev"},
+			IpAddresses: []string{"100.64.0.1", "fd7a:115c:a1e0::1"},
+			Online:      true,
+		},
+	}
+
+	want := []sd.Device{
+		{
+			Machine:  "laptop",
+			Hostname: "alice-laptop",
+			User:     "alice",
+			Tags:     []string{"tag:dev"},
+			Online:   true,
+			IPv4:     "100.64.0.1",
+			IPv6:     "fd7a:115c:a1e0::1",
+		},
+	}
+	if got := sdNodes(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,49 @@
+package headscale
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const serverSubsystem = "server"
+
+var serverInfoDesc = newDesc(
+	serverSubsystem,
+	"info",
+	"Headscale API version detected from the RPCs the server implements",
+	[]string{"version"},
+)
+
+type HeadscaleServerCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(serverSubsystem, NewHeadscaleServerCollector)
+}
+
+func NewHeadscaleServerCollector(config collectorConfig) (Collector, error) {
+	return &HeadscaleServerCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c HeadscaleServerCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting server metrics")
+
+	version, err := client.ServerVersion(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error detecting Headscale version", "error", err)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(serverInfoDesc, prometheus.GaugeValue, 1, version)
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package headscale
+
+import "testing"
+
+func TestHeadscaleServerCollector_Update(t *testing.T) {
+	collector, err := NewHeadscaleServerCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create server collector: %v", err)
+	}
+
+	client := &mockHeadscaleClient{version: APIVersion026}
+
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_server_info Headscale API version detected from the RPCs the server implements
+# TYPE headscale_server_info gauge
+headscale_server_info{version="0.26"} 1
+`
+	gatherMetrics(t, metrics, expected)
+}
@@ -0,0 +1,89 @@
+package headscale
+
+import (
+	"context"
+	"time"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+var (
+	scrapeCacheHitsDesc = newDesc(
+		"scrape",
+		"cache_hits_total",
+		"headscale_exporter: Number of API reads served from the per-scrape snapshot.",
+		[]string{"resource"},
+	)
+	scrapeCacheMissesDesc = newDesc(
+		"scrape",
+		"cache_misses_total",
+		"headscale_exporter: Number of API reads that were fetched from the Headscale API.",
+		[]string{"resource"},
+	)
+)
+
+// snapshotClient serves the responses of a HeadscaleClient from a per-scrape
+// cache. Writes are passed through.
+type snapshotClient struct {
+	client HeadscaleClient
+	cache  *snapshot.Cache
+}
+
+func newSnapshotClient(client HeadscaleClient, stats *snapshot.Stats) *snapshotClient {
+	return &snapshotClient{
+		client: client,
+		cache:  snapshot.New(stats),
+	}
+}
+
+func (s *snapshotClient) ListUsers(ctx context.Context) ([]*headscalev1.User, error) {
+	return snapshot.Fetch(s.cache, "users", func() ([]*headscalev1.User, error) {
+		return s.client.ListUsers(ctx)
+	})
+}
+
+func (s *snapshotClient) ListNodes(ctx context.Context) ([]*headscalev1.Node, error) {
+	return snapshot.Fetch(s.cache, "nodes", func() ([]*headscalev1.Node, error) {
+		return s.client.ListNodes(ctx)
+	})
+}
+
+func (s *snapshotClient) ListAPIKeys(ctx context.Context) ([]*headscalev1.ApiKey, error) {
+	return snapshot.Fetch(s.cache, "api_keys", func() ([]*headscalev1.ApiKey, error) {
+		return s.client.ListAPIKeys(ctx)
+	})
+}
+
+func (s *snapshotClient) ListPreAuthKeys(ctx context.Context) ([]*headscalev1.PreAuthKey, error) {
+	return snapshot.Fetch(s.cache, "pre_auth_keys", func() ([]*headscalev1.PreAuthKey, error) {
+		return s.client.ListPreAuthKeys(ctx)
+	})
+}
+
+func (s *snapshotClient) Health(ctx context.Context) (*headscalev1.HealthResponse, error) {
+	return snapshot.Fetch(s.cache, "health", func() (*headscalev1.HealthResponse, error) {
+		return s.client.Health(ctx)
+	})
+}
+
+func (s *snapshotClient) GetPolicy(ctx context.Context) (*headscalev1.GetPolicyResponse, error) {
+	return snapshot.Fetch(s.cache, "policy", func() (*headscalev1.GetPolicyResponse, error) {
+		return s.client.GetPolicy(ctx)
+	})
+}
+
+func (s *snapshotClient) ServerVersion(ctx context.Context) (string, error) {
+	return snapshot.Fetch(s.cache, "version", func() (string, error) {
+		return s.client.ServerVersion(ctx)
+	})
+}
+
+func (s *snapshotClient) CreateAPIKey(ctx context.Context, expiration time.Time) (string, error) {
+	return s.client.CreateAPIKey(ctx, expiration)
+}
+
+func (s *snapshotClient) ExpireAPIKey(ctx context.Context, prefix string) error {
+	return s.client.ExpireAPIKey(ctx, prefix)
+}
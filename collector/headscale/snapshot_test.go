@@ -0,0 +1,45 @@
+package headscale
+
+import (
+	"context"
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+type countingHeadscaleClient struct {
+	mockHeadscaleClient
+	nodeCalls int
+}
+
+func (m *countingHeadscaleClient) ListNodes(ctx context.Context) ([]*headscalev1.Node, error) {
+	m.nodeCalls++
+	return m.mockHeadscaleClient.ListNodes(ctx)
+}
+
+func TestSnapshotClient_ListNodesOncePerScrape(t *testing.T) {
+	client := &countingHeadscaleClient{
+		mockHeadscaleClient: mockHeadscaleClient{
+			users: []*headscalev1.User{{Id: 1, Name: "alice"}},
+			nodes: []*headscalev1.Node{{Id: 10, Name: "laptop"}},
+		},
+	}
+	stats := snapshot.NewStats()
+
+	for range 2 {
+		scrape := newSnapshotClient(client, stats)
+		for _, name := range []string{"nodes", "inventory"} {
+			collector, err := factories[name](collectorConfig{logger: testLogger(t)})
+			if err != nil {
+				t.Fatalf("failed to create %s collector: %v", name, err)
+			}
+			collectFromCollector(t, collector, scrape)
+		}
+	}
+
+	if client.nodeCalls != 2 {
+		t.Fatalf("expected nodes to be listed once per scrape, got %d calls", client.nodeCalls)
+	}
+}
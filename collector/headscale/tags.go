@@ -0,0 +1,136 @@
+package headscale
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const tagsSubsystem = "tags"
+
+const (
+	tagSourceForced    = "forced"
+	tagSourceRequested = "requested"
+	tagSourceValid     = "valid"
+	tagSourceInvalid   = "invalid"
+)
+
+// Field numbers of the tag lists Headscale reported before 0.28. They are
+// reserved in the current Node message, so the generated client keeps them
+// as unknown fields.
+const (
+	legacyForcedTagsField  protowire.Number = 18
+	legacyInvalidTagsField protowire.Number = 19
+	legacyValidTagsField   protowire.Number = 20
+)
+
+var (
+	tagsNodeDesc = newDesc(
+		tagsSubsystem,
+		"node_info",
+		"Tag of a node by source: forced by an admin, requested by the node, and requested tags valid or invalid under the policy",
+		[]string{"id", "name", "user", "tag", "source"},
+	)
+	tagsNodesDesc = newDesc(
+		tagsSubsystem,
+		"nodes",
+		"Number of nodes with the tag by source",
+		[]string{"tag", "source"},
+	)
+)
+
+// nodeTags holds the tags of a node by source.
+type nodeTags map[string][]string
+
+// tagsOfNode returns the tags of node by source. Servers before 0.28 report
+// forced, valid and invalid tags, the requested tags are the valid and
+// invalid ones. Newer servers only report the tags the node has, which are
+// reported as valid since rejected tags and the origin of tags are unknown.
+func tagsOfNode(node *headscalev1.Node) nodeTags {
+	tags := nodeTags{}
+	for _, tag := range node.GetTags() {
+		tags.add(tagSourceValid, tag)
+	}
+
+	// Malformed unknown fields only lose the legacy tags.
+	_ = rangeFields(node.ProtoReflect().GetUnknown(), func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case legacyForcedTagsField:
+			tags.add(tagSourceForced, string(value))
+		case legacyValidTagsField:
+			tags.add(tagSourceValid, string(value))
+			tags.add(tagSourceRequested, string(value))
+		case legacyInvalidTagsField:
+			tags.add(tagSourceInvalid, string(value))
+			tags.add(tagSourceRequested, string(value))
+		}
+		return nil
+	})
+	return tags
+}
+
+func (t nodeTags) add(source, tag string) {
+	if !slices.Contains(t[source], tag) {
+		t[source] = append(t[source], tag)
+	}
+}
+
+type HeadscaleTagsCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(tagsSubsystem, NewHeadscaleTagsCollector)
+}
+
+func NewHeadscaleTagsCollector(config collectorConfig) (Collector, error) {
+	return &HeadscaleTagsCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c HeadscaleTagsCollector) Update(
+	ctx context.Context,
+	client HeadscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting tags metrics")
+
+	nodes, err := client.ListNodes(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Headscale nodes", "error", err)
+		return err
+	}
+
+	type tagSource struct {
+		tag    string
+		source string
+	}
+	counts := make(map[tagSource]int)
+
+	for _, node := range nodes {
+		nodeID := formatUint(node.GetId())
+		userName := node.GetUser().GetName()
+
+		for source, tags := range tagsOfNode(node) {
+			for _, tag := range tags {
+				ch <- prometheus.MustNewConstMetric(tagsNodeDesc, prometheus.GaugeValue, 1,
+					nodeID, node.GetName(), userName, tag, source,
+				)
+				counts[tagSource{tag: tag, source: source}]++
+			}
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(tagsNodesDesc, prometheus.GaugeValue, float64(count),
+			key.tag, key.source,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package headscale
+
+import (
+	"testing"
+
+	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// legacyNode returns a node as a Headscale server before 0.28 reports it,
+// with the deprecated tag lists the current client does not know.
+func legacyNode(node *headscalev1.Node, forced, valid, invalid []string) *headscalev1.Node {
+	var unknown []byte
+	for num, tags := range map[protowire.Number][]string{
+		legacyForcedTagsField:  forced,
+		legacyValidTagsField:   valid,
+		legacyInvalidTagsField: invalid,
+	} {
+		for _, tag := range tags {
+			unknown = protowire.AppendTag(unknown, num, protowire.BytesType)
+			unknown = protowire.AppendString(unknown, tag)
+		}
+	}
+	node.ProtoReflect().SetUnknown(unknown)
+	return node
+}
+
+func TestHeadscaleTagsCollector_Update(t *testing.T) {
+	collector, err := NewHeadscaleTagsCollector(collectorConfig{logger: testLogger(t)})
+	if err != nil {
+		t.Fatalf("failed to create tags collector: %v", err)
+	}
+
+	client := &mockHeadscaleClient{
+		nodes: []*headscalev1.Node{
+			legacyNode(
+				&headscalev1.Node{Id: 1, Name: "node-one", User: &headscalev1.User{Name: "alice"}},
+				[]string{"tag:server"},
+				[]string{"tag:web"},
+				[]string{"tag:prod"},
+			),
+			{
+				Id:   2,
+				Name: "node-two",
+				User: &headscalev1.User{Name: "bob"},
+				Tags: []string{"tag:web"},
+			},
+		},
+	}
+
+	metrics := collectFromCollector(t, collector, client)
+	expected := `
+# HELP headscale_tags_node_info Tag of a node by source: forced by an admin, requested by the node, and requested tags valid or invalid under the policy
+# TYPE headscale_tags_node_info gauge
+headscale_tags_node_info{id="1",name="node-one",source="forced",tag="tag:server",user="alice"} 1
+headscale_tags_node_info{id="1",name="node-one",source="invalid",tag="tag:prod",user="alice"} 1
+headscale_tags_node_info{id="1",name="node-one",source="requested",tag="tag:prod",user="alice"} 1
+headscale_tags_node_info{id="1",name="node-one",source="requested",tag="tag:web",user="alice"} 1
+headscale_tags_node_info{id="1",name="node-one",source="valid",tag="tag:web",user="alice"} 1
+headscale_tags_node_info{id="2",name="node-two",source="valid",tag="tag:web",user="bob"} 1
+# HELP headscale_tags_nodes Number of nodes with the tag by source
+# TYPE headscale_tags_nodes gauge
+headscale_tags_nodes{source="forced",tag="tag:server"} 1
+headscale_tags_nodes{source="invalid",tag="tag:prod"} 1
+headscale_tags_nodes{source="requested",tag="tag:prod"} 1
+headscale_tags_nodes{source="requested",tag="tag:web"} 1
+headscale_tags_nodes{source="valid",tag="tag:web"} 2
+`
+	gatherMetrics(t, metrics, expected)
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"strings"
 	"testing"
+	"time"
 
 	headscalev1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,11 +19,16 @@ type mockHeadscaleClient struct {
 	apiKeys      []*headscalev1.ApiKey
 	preAuthKeys  []*headscalev1.PreAuthKey
 	healthResp   *headscalev1.HealthResponse
+	policyResp   *headscalev1.GetPolicyResponse
+	version      string
+	createdKeys  []time.Time
+	expiredKeys  []string
 	listUsersErr error
 	listNodesErr error
 	apiKeysErr   error
 	preAuthErr   error
 	healthErr    error
+	policyErr    error
 }
 
 func (m *mockHeadscaleClient) ListUsers(ctx context.Context) ([]*headscalev1.User, error) {
@@ -62,6 +68,30 @@ func (m *mockHeadscaleClient) Health(ctx context.Context) (*headscalev1.HealthRe
 	return m.healthResp, nil
 }
 
+func (m *mockHeadscaleClient) GetPolicy(ctx context.Context) (*headscalev1.GetPolicyResponse, error) {
+	if m.policyErr != nil {
+		return nil, m.policyErr
+	}
+	return m.policyResp, nil
+}
+
+func (m *mockHeadscaleClient) ServerVersion(ctx context.Context) (string, error) {
+	if m.version == "" {
+		return APIVersion028, nil
+	}
+	return m.version, nil
+}
+
+func (m *mockHeadscaleClient) CreateAPIKey(ctx context.Context, expiration time.Time) (string, error) {
+	m.createdKeys = append(m.createdKeys, expiration)
+	return "hskey-api-newprefix123-secret", nil
+}
+
+func (m *mockHeadscaleClient) ExpireAPIKey(ctx context.Context, prefix string) error {
+	m.expiredKeys = append(m.expiredKeys, prefix)
+	return nil
+}
+
 func gatherMetrics(t *testing.T, metrics []prometheus.Metric, expected string) {
 	t.Helper()
 	reg := prometheus.NewRegistry()
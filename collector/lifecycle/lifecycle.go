@@ -0,0 +1,117 @@
+// Package lifecycle tracks devices across scrapes to count when they join or
+// leave, go online or offline and change authorization.
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/fileutil"
+)
+
+// State is the part of a device that transitions are counted for.
+type State struct {
+	Online     bool `json:"online"`
+	Authorized bool `json:"authorized"`
+}
+
+// Counts are the transitions observed since the exporter started.
+type Counts struct {
+	Added        float64
+	Removed      float64
+	WentOnline   float64
+	WentOffline  float64
+	Authorized   float64
+	Deauthorized float64
+}
+
+// persistedState is the tracker state written to the state file.
+type persistedState struct {
+	Devices map[string]State `json:"devices"`
+}
+
+// Tracker compares each observation with the previous one, keyed by device
+// ID. The first observation only records a baseline unless a previous state
+// was loaded from the state file.
+type Tracker struct {
+	stateFile string
+
+	mtx     sync.Mutex
+	devices map[string]State
+	counts  Counts
+}
+
+// NewTracker returns a tracker that persists its state to stateFile when it is
+// not empty.
+func NewTracker(stateFile string) (*Tracker, error) {
+	t := &Tracker{stateFile: stateFile}
+	if stateFile == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse lifecycle state: %w", err)
+	}
+	t.devices = state.Devices
+	if t.devices == nil {
+		t.devices = make(map[string]State)
+	}
+	return t, nil
+}
+
+// Observe records the current devices and returns the updated counts. The
+// returned error is only set when saving the state file fails, the counts are
+// valid either way.
+func (t *Tracker) Observe(current map[string]State) (Counts, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.devices != nil {
+		for id, state := range current {
+			previous, ok := t.devices[id]
+			if !ok {
+				t.counts.Added++
+				continue
+			}
+			switch {
+			case state.Online && !previous.Online:
+				t.counts.WentOnline++
+			case !state.Online && previous.Online:
+				t.counts.WentOffline++
+			}
+			switch {
+			case state.Authorized && !previous.Authorized:
+				t.counts.Authorized++
+			case !state.Authorized && previous.Authorized:
+				t.counts.Deauthorized++
+			}
+		}
+		for id := range t.devices {
+			if _, ok := current[id]; !ok {
+				t.counts.Removed++
+			}
+		}
+	}
+	t.devices = current
+
+	if t.stateFile == "" {
+		return t.counts, nil
+	}
+	data, err := json.Marshal(persistedState{Devices: current})
+	if err != nil {
+		return t.counts, err
+	}
+	return t.counts, fileutil.WriteFileAtomic(t.stateFile, data)
+}
@@ -0,0 +1,74 @@
+package lifecycle
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTracker_Observe(t *testing.T) {
+	tracker, err := NewTracker("")
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	observations := []map[string]State{
+		{
+			"a": {Online: true, Authorized: true},
+			"b": {Online: false, Authorized: false},
+		},
+		{
+			"a": {Online: false, Authorized: true},
+			"b": {Online: true, Authorized: true},
+			"c": {Online: true, Authorized: true},
+		},
+		{
+			"a": {Online: true, Authorized: false},
+			"c": {Online: true, Authorized: true},
+		},
+	}
+
+	var counts Counts
+	for _, observation := range observations {
+		counts, err = tracker.Observe(observation)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	expected := Counts{
+		Added:        1,
+		Removed:      1,
+		WentOnline:   2,
+		WentOffline:  1,
+		Authorized:   1,
+		Deauthorized: 1,
+	}
+	if counts != expected {
+		t.Errorf("expected %+v, got %+v", expected, counts)
+	}
+}
+
+func TestTracker_StateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "lifecycle.json")
+
+	tracker, err := NewTracker(stateFile)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+	if _, err := tracker.Observe(map[string]State{"a": {Online: true}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A restarted tracker counts changes against the persisted state.
+	restarted, err := NewTracker(stateFile)
+	if err != nil {
+		t.Fatalf("failed to load tracker: %v", err)
+	}
+	counts, err := restarted.Observe(map[string]State{"a": {Online: false}, "b": {}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if counts.Added != 1 || counts.WentOffline != 1 {
+		t.Errorf("expected changes since the persisted state, got %+v", counts)
+	}
+}
@@ -0,0 +1,322 @@
+// Package local collects the state of the tailscaled running next to the
+// exporter from its LocalAPI socket.
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tslocal "tailscale.com/client/local"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+const (
+	namespace = "tailscale_local"
+
+	// DefaultSocket is where tailscaled serves the LocalAPI on Linux.
+	DefaultSocket = "/var/run/tailscale/tailscaled.sock"
+
+	// scrapeTimeout bounds a scrape, so that a LocalAPI request that never
+	// returns does not hold the metrics request. Longer probe timeouts extend
+	// it.
+	scrapeTimeout = 30 * time.Second
+)
+
+var (
+	factories = make(
+		map[string]func(collectorConfig) (Collector, error),
+	)
+	initiatedCollectorsMtx = sync.Mutex{}
+	initiatedCollectors    = make(map[string]Collector)
+)
+
+var (
+	upDesc = newDesc(
+		"",
+		"up",
+		"Whether the tailscaled LocalAPI is accessible.",
+		nil,
+	)
+	scrapeDurationDesc = newDesc(
+		"scrape",
+		"collector_duration_seconds",
+		"tailscale_exporter: Duration of a local collector scrape.",
+		[]string{"collector"},
+	)
+	scrapeSuccessDesc = newDesc(
+		"scrape",
+		"collector_success",
+		"tailscale_exporter: Whether a local collector succeeded.",
+		[]string{"collector"},
+	)
+)
+
+func boolAsFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Config holds optional settings shared by the local collectors.
+type Config struct {
+	// ProbeTags, ProbeUsers and ProbeNames enable the probe collector and
+	// select the peers it pings: peers with one of the tags, owned by one of
+	// the login names or whose MagicDNS short name or hostname matches one of
+	// the path.Match patterns.
+	ProbeTags  []string
+	ProbeUsers []string
+	ProbeNames []string
+	// ProbeType is one of ProbeTypeDisco (default), ProbeTypeTSMP,
+	// ProbeTypeICMP or ProbeTypeTCP.
+	ProbeType string
+	// ProbePort is the port TCP probes connect to.
+	ProbePort int
+	// ProbeTimeout bounds each probe.
+	ProbeTimeout time.Duration
+}
+
+type collectorConfig struct {
+	logger *slog.Logger
+	Config
+}
+
+func newDesc(
+	subsystem, name, help string,
+	variableLabels []string,
+) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, name),
+		help, variableLabels, nil,
+	)
+}
+
+func registerCollector(
+	name string,
+	createFunc func(collectorConfig) (Collector, error),
+) {
+	factories[name] = createFunc
+}
+
+type Collector interface {
+	Update(
+		ctx context.Context,
+		client LocalClient,
+		ch chan<- prometheus.Metric,
+	) error
+}
+
+// LocalClient is the subset of the tailscaled LocalAPI the collectors use.
+type LocalClient interface {
+	Status(ctx context.Context) (*ipnstate.Status, error)
+	// NetInfo returns the latest netcheck results of this node.
+	NetInfo(ctx context.Context) (*tailcfg.NetInfo, error)
+	Ping(ctx context.Context, ip netip.Addr, pingType tailcfg.PingType) (*ipnstate.PingResult, error)
+	DialTCP(ctx context.Context, host string, port uint16) (net.Conn, error)
+}
+
+// LocalAPIClient talks to tailscaled or a tsnet node over the LocalAPI.
+type LocalAPIClient struct {
+	client *tslocal.Client
+}
+
+// NewLocalAPIClient returns a client for the LocalAPI served on socket.
+func NewLocalAPIClient(socket string) *LocalAPIClient {
+	return WrapLocalAPIClient(&tslocal.Client{
+		Socket:        socket,
+		UseSocketOnly: true,
+	})
+}
+
+// WrapLocalAPIClient returns a client for an existing LocalAPI client, such
+// as the one of an embedded tsnet node.
+func WrapLocalAPIClient(client *tslocal.Client) *LocalAPIClient {
+	return &LocalAPIClient{
+		client: client,
+	}
+}
+
+func (c *LocalAPIClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	return c.client.Status(ctx)
+}
+
+func (c *LocalAPIClient) Ping(
+	ctx context.Context,
+	ip netip.Addr,
+	pingType tailcfg.PingType,
+) (*ipnstate.PingResult, error) {
+	return c.client.Ping(ctx, ip, pingType)
+}
+
+func (c *LocalAPIClient) DialTCP(ctx context.Context, host string, port uint16) (net.Conn, error) {
+	return c.client.DialTCP(ctx, host, port)
+}
+
+// NetInfo reads the netcheck results from the self node of the current
+// network map, which tailscaled sends in the first message of an IPN bus
+// watch. Without a network map, e.g. when logged out or starting, the first
+// message has none and no other is sent until the state changes.
+func (c *LocalAPIClient) NetInfo(ctx context.Context) (*tailcfg.NetInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watcher, err := c.client.WatchIPNBus(ctx, ipn.NotifyInitialNetMap)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = watcher.Close() }()
+
+	notify, err := watcher.Next()
+	if err != nil {
+		return nil, err
+	}
+	if notify.NetMap == nil {
+		if notify.State != nil {
+			return nil, fmt.Errorf("tailscaled has no network map in state %s", *notify.State)
+		}
+		return nil, errors.New("tailscaled has no network map")
+	}
+	self := notify.NetMap.SelfNode
+	if !self.Valid() || !self.Hostinfo().Valid() || !self.Hostinfo().NetInfo().Valid() {
+		return nil, errors.New("network map has no netcheck results")
+	}
+	return self.Hostinfo().NetInfo().AsStruct(), nil
+}
+
+// LocalCollector collects the state of the local tailscaled.
+type LocalCollector struct {
+	client     LocalClient
+	timeout    time.Duration
+	cacheStats *snapshot.Stats
+
+	Collectors map[string]Collector
+	logger     *slog.Logger
+}
+
+// NewLocalCollector creates the local collector.
+func NewLocalCollector(
+	logger *slog.Logger,
+	client LocalClient,
+	config Config,
+) (*LocalCollector, error) {
+	l := &LocalCollector{
+		logger:     logger,
+		client:     client,
+		timeout:    max(scrapeTimeout, config.ProbeTimeout),
+		cacheStats: snapshot.NewStats(),
+	}
+
+	collectors := make(map[string]Collector)
+	initiatedCollectorsMtx.Lock()
+	defer initiatedCollectorsMtx.Unlock()
+	for key := range factories {
+		if collector, ok := initiatedCollectors[key]; ok {
+			collectors[key] = collector
+		} else {
+			coll, err := factories[key](collectorConfig{
+				logger: logger.With("collector", key),
+				Config: config,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if coll == nil {
+				// The collector is disabled by its configuration.
+				continue
+			}
+			collectors[key] = coll
+			initiatedCollectors[key] = coll
+		}
+	}
+
+	l.Collectors = collectors
+	return l, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (l *LocalCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- scrapeDurationDesc
+	ch <- scrapeSuccessDesc
+	ch <- scrapeCacheHitsDesc
+	ch <- scrapeCacheMissesDesc
+}
+
+// Collect implements the prometheus.Collector interface. The collectors are
+// skipped when tailscaled does not answer, which is reported by up.
+func (l *LocalCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	// Every collector of this scrape reads from the same snapshot.
+	client := newSnapshotClient(l.client, l.cacheStats)
+	if _, err := client.Status(ctx); err != nil {
+		l.logger.ErrorContext(ctx, "Error getting tailscaled status", "error", err.Error())
+		l.cacheStats.Collect(ch, scrapeCacheHitsDesc, scrapeCacheMissesDesc)
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(l.Collectors))
+	for name, c := range l.Collectors {
+		go func(name string, c Collector) {
+			execute(ctx, name, c, client, ch, l.logger)
+			wg.Done()
+		}(name, c)
+	}
+	wg.Wait()
+	l.cacheStats.Collect(ch, scrapeCacheHitsDesc, scrapeCacheMissesDesc)
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+}
+
+func execute(
+	ctx context.Context,
+	name string,
+	c Collector,
+	client LocalClient,
+	ch chan<- prometheus.Metric,
+	logger *slog.Logger,
+) {
+	begin := time.Now()
+	err := c.Update(ctx, client, ch)
+	duration := time.Since(begin)
+	var success float64
+
+	if err != nil {
+		logger.ErrorContext(
+			ctx,
+			"collector failed",
+			"name",
+			name,
+			"duration_seconds",
+			duration.Seconds(),
+			"err",
+			err,
+		)
+		success = 0
+	} else {
+		logger.DebugContext(
+			ctx,
+			"collector succeeded",
+			"name",
+			name,
+			"duration_seconds",
+			duration.Seconds(),
+		)
+		success = 1
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+}
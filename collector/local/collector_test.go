@@ -0,0 +1,185 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+type TestMetricCollector struct {
+	metrics []prometheus.Metric
+}
+
+func (c *TestMetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	// We don't need to describe since we're using pre-collected metrics
+}
+
+func (c *TestMetricCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range c.metrics {
+		ch <- metric
+	}
+}
+
+// mockLocalClient implements LocalClient for collectors that send requests
+// the fake LocalAPI does not serve.
+type mockLocalClient struct {
+	status      *ipnstate.Status
+	netInfo     *tailcfg.NetInfo
+	pingResults map[netip.Addr]*ipnstate.PingResult
+	dialErrs    map[string]error
+}
+
+func (m *mockLocalClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	return m.status, nil
+}
+
+func (m *mockLocalClient) NetInfo(ctx context.Context) (*tailcfg.NetInfo, error) {
+	return m.netInfo, nil
+}
+
+func (m *mockLocalClient) Ping(
+	ctx context.Context,
+	ip netip.Addr,
+	pingType tailcfg.PingType,
+) (*ipnstate.PingResult, error) {
+	result, ok := m.pingResults[ip]
+	if !ok {
+		return nil, errors.New("timeout")
+	}
+	return result, nil
+}
+
+func (m *mockLocalClient) DialTCP(ctx context.Context, host string, port uint16) (net.Conn, error) {
+	if err := m.dialErrs[host]; err != nil {
+		return nil, err
+	}
+	client, server := net.Pipe()
+	_ = server.Close()
+	return client, nil
+}
+
+// newFakeLocalAPI serves status and, as the first message of an IPN bus
+// watch, notify on a LocalAPI unix socket and returns the socket path.
+func newFakeLocalAPI(t *testing.T, status *ipnstate.Status, notify string) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "tailscaled.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, notify+"\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	server := &http.Server{Handler: mux}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return socket
+}
+
+func collectFromCollector(t *testing.T, c Collector, client LocalClient) []prometheus.Metric {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 128)
+	err := c.Update(t.Context(), client, ch)
+	close(ch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func TestLocalCollector(t *testing.T) {
+	socket := newFakeLocalAPI(t, &ipnstate.Status{
+		Version:      "1.94.1",
+		BackendState: "Running",
+	}, `{"NetMap":{"SelfNode":{"Hostinfo":{"NetInfo":{"WorkingUDP":true}}}}}`)
+
+	collector, err := NewLocalCollector(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		NewLocalAPIClient(socket),
+		Config{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP tailscale_local_up Whether the tailscaled LocalAPI is accessible.
+# TYPE tailscale_local_up gauge
+tailscale_local_up 1
+# HELP tailscale_local_netcheck_udp Whether UDP traffic to DERP servers works from the local node.
+# TYPE tailscale_local_netcheck_udp gauge
+tailscale_local_netcheck_udp 1
+# HELP tailscale_local_scrape_cache_misses_total tailscale_exporter: Number of LocalAPI reads that were fetched from tailscaled.
+# TYPE tailscale_local_scrape_cache_misses_total counter
+tailscale_local_scrape_cache_misses_total{resource="netinfo"} 1
+tailscale_local_scrape_cache_misses_total{resource="status"} 1
+`
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	err = testutil.GatherAndCompare(
+		registry,
+		strings.NewReader(expected),
+		"tailscale_local_up",
+		"tailscale_local_netcheck_udp",
+		"tailscale_local_scrape_cache_misses_total",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalCollectorUnreachable(t *testing.T) {
+	collector, err := NewLocalCollector(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		NewLocalAPIClient(filepath.Join(t.TempDir(), "missing.sock")),
+		Config{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP tailscale_local_up Whether the tailscaled LocalAPI is accessible.
+# TYPE tailscale_local_up gauge
+tailscale_local_up 0
+`
+
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "tailscale_local_up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(collector, "tailscale_local_scrape_collector_success"); count != 0 {
+		t.Fatalf("expected collectors to be skipped, got %d results", count)
+	}
+}
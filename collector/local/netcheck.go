@@ -0,0 +1,124 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/types/opt"
+)
+
+const netcheckSubsystem = "netcheck"
+
+var (
+	netcheckUDPDesc = newDesc(
+		netcheckSubsystem,
+		"udp",
+		"Whether UDP traffic to DERP servers works from the local node.",
+		nil,
+	)
+	netcheckIPv6Desc = newDesc(
+		netcheckSubsystem,
+		"ipv6",
+		"Whether IPv6 connectivity works from the local node.",
+		nil,
+	)
+	netcheckMappingVariesDesc = newDesc(
+		netcheckSubsystem,
+		"mapping_varies_by_dest_ip",
+		"Whether the NAT of the local node maps ports differently per destination, which prevents most direct connections.",
+		nil,
+	)
+	netcheckPortMappingDesc = newDesc(
+		netcheckSubsystem,
+		"port_mapping",
+		"Whether a port mapping protocol is available on the local network.",
+		[]string{"protocol"},
+	)
+	netcheckPreferredDERPDesc = newDesc(
+		netcheckSubsystem,
+		"preferred_derp_region_info",
+		"DERP region with the lowest latency from the local node.",
+		[]string{"region_id"},
+	)
+	netcheckDERPLatencyDesc = newDesc(
+		netcheckSubsystem,
+		"derp_latency_seconds",
+		"Latency from the local node to a DERP region.",
+		[]string{"region_id", "family"},
+	)
+)
+
+// LocalNetcheckCollector exports the latest netcheck results of the local
+// node.
+type LocalNetcheckCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(netcheckSubsystem, NewLocalNetcheckCollector)
+}
+
+func NewLocalNetcheckCollector(config collectorConfig) (Collector, error) {
+	return &LocalNetcheckCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c LocalNetcheckCollector) Update(
+	ctx context.Context,
+	client LocalClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting local netcheck metrics")
+
+	netInfo, err := client.NetInfo(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting netcheck results", "error", err.Error())
+		return err
+	}
+
+	sendOptBool(ch, netcheckUDPDesc, netInfo.WorkingUDP)
+	sendOptBool(ch, netcheckIPv6Desc, netInfo.WorkingIPv6)
+	sendOptBool(ch, netcheckMappingVariesDesc, netInfo.MappingVariesByDestIP)
+	sendOptBool(ch, netcheckPortMappingDesc, netInfo.UPnP, "upnp")
+	sendOptBool(ch, netcheckPortMappingDesc, netInfo.PMP, "pmp")
+	sendOptBool(ch, netcheckPortMappingDesc, netInfo.PCP, "pcp")
+
+	if netInfo.PreferredDERP != 0 {
+		ch <- prometheus.MustNewConstMetric(
+			netcheckPreferredDERPDesc, prometheus.GaugeValue, 1,
+			strconv.Itoa(netInfo.PreferredDERP),
+		)
+	}
+
+	// Latencies are keyed by "<region id>-<v4|v6>".
+	for key, latency := range netInfo.DERPLatency {
+		region, family, ok := strings.Cut(key, "-")
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			netcheckDERPLatencyDesc, prometheus.GaugeValue, latency,
+			region, family,
+		)
+	}
+
+	return nil
+}
+
+// sendOptBool sends b when netcheck determined it.
+func sendOptBool(
+	ch chan<- prometheus.Metric,
+	desc *prometheus.Desc,
+	b opt.Bool,
+	labelValues ...string,
+) {
+	value, ok := b.Get()
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolAsFloat(value), labelValues...)
+}
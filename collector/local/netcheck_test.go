@@ -0,0 +1,74 @@
+package local
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/ipn/ipnstate"
+)
+
+func TestLocalNetcheckCollector_Update(t *testing.T) {
+	// Netcheck results arrive with the network map of the self node, unset
+	// results such as PCP are not exported.
+	notify := `{"State":6,"NetMap":{"SelfNode":{"ID":1,"Hostinfo":{"NetInfo":{
+	"MappingVariesByDestIP":false,
+	"WorkingIPv6":false,
+	"WorkingUDP":true,
+	"UPnP":true,
+	"PMP":false,
+	"PreferredDERP":4,
+	"DERPLatency":{"4-v4":0.012,"4-v6":0.015,"9-v4":0.09}
+}}}}}`
+	socket := newFakeLocalAPI(t, &ipnstate.Status{BackendState: "Running"}, notify)
+
+	collector := &LocalNetcheckCollector{log: slog.Default()}
+	metrics := collectFromCollector(t, collector, NewLocalAPIClient(socket))
+
+	expected := `
+# HELP tailscale_local_netcheck_derp_latency_seconds Latency from the local node to a DERP region.
+# TYPE tailscale_local_netcheck_derp_latency_seconds gauge
+tailscale_local_netcheck_derp_latency_seconds{family="v4",region_id="4"} 0.012
+tailscale_local_netcheck_derp_latency_seconds{family="v4",region_id="9"} 0.09
+tailscale_local_netcheck_derp_latency_seconds{family="v6",region_id="4"} 0.015
+# HELP tailscale_local_netcheck_ipv6 Whether IPv6 connectivity works from the local node.
+# TYPE tailscale_local_netcheck_ipv6 gauge
+tailscale_local_netcheck_ipv6 0
+# HELP tailscale_local_netcheck_mapping_varies_by_dest_ip Whether the NAT of the local node maps ports differently per destination, which prevents most direct connections.
+# TYPE tailscale_local_netcheck_mapping_varies_by_dest_ip gauge
+tailscale_local_netcheck_mapping_varies_by_dest_ip 0
+# HELP tailscale_local_netcheck_port_mapping Whether a port mapping protocol is available on the local network.
+# TYPE tailscale_local_netcheck_port_mapping gauge
+tailscale_local_netcheck_port_mapping{protocol="pmp"} 0
+tailscale_local_netcheck_port_mapping{protocol="upnp"} 1
+# HELP tailscale_local_netcheck_preferred_derp_region_info DERP region with the lowest latency from the local node.
+# TYPE tailscale_local_netcheck_preferred_derp_region_info gauge
+tailscale_local_netcheck_preferred_derp_region_info{region_id="4"} 1
+# HELP tailscale_local_netcheck_udp Whether UDP traffic to DERP servers works from the local node.
+# TYPE tailscale_local_netcheck_udp gauge
+tailscale_local_netcheck_udp 1
+`
+
+	err := testutil.CollectAndCompare(
+		&TestMetricCollector{metrics: metrics},
+		strings.NewReader(expected),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalAPIClient_NetInfoWithoutNetMap(t *testing.T) {
+	// A logged out tailscaled sends its state without a network map and then
+	// nothing until the state changes.
+	socket := newFakeLocalAPI(t, &ipnstate.Status{BackendState: "NeedsLogin"}, `{"State":2}`)
+
+	_, err := NewLocalAPIClient(socket).NetInfo(t.Context())
+	if err == nil {
+		t.Fatal("expected an error without a network map")
+	}
+	if !strings.Contains(err.Error(), "NeedsLogin") {
+		t.Errorf("expected the state in the error, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/ipn/ipnstate"
+)
+
+const peersSubsystem = "peer"
+
+const (
+	connectionDirect    = "direct"
+	connectionPeerRelay = "peer_relay"
+	connectionDERP      = "derp"
+	connectionNone      = "none"
+)
+
+var peerLabels = []string{"peer_id", "peer"}
+
+var (
+	peerInfoDesc = newDesc(
+		peersSubsystem,
+		"info",
+		"Information about a peer as seen by the local node.",
+		append(peerLabels, "hostname", "dns_name", "os"),
+	)
+	peerOnlineDesc = newDesc(
+		peersSubsystem,
+		"online",
+		"Whether the peer is connected to the control plane.",
+		peerLabels,
+	)
+	peerActiveDesc = newDesc(
+		peersSubsystem,
+		"active",
+		"Whether the local node has recently exchanged traffic with the peer.",
+		peerLabels,
+	)
+	peerConnectionDesc = newDesc(
+		peersSubsystem,
+		"connection",
+		"How the local node reaches the peer: direct, peer_relay, derp or none, 1 for the current path.",
+		append(peerLabels, "connection"),
+	)
+	peerDERPRegionDesc = newDesc(
+		peersSubsystem,
+		"derp_region_info",
+		"Home DERP region of the peer, used to relay traffic when no direct path exists.",
+		append(peerLabels, "region"),
+	)
+	peerEndpointsDesc = newDesc(
+		peersSubsystem,
+		"endpoints",
+		"Number of candidate endpoints known for the peer.",
+		peerLabels,
+	)
+	peerCurrentEndpointDesc = newDesc(
+		peersSubsystem,
+		"current_endpoint_info",
+		"Endpoint of the current direct or peer relay path to the peer.",
+		append(peerLabels, "endpoint"),
+	)
+	peerLastHandshakeDesc = newDesc(
+		peersSubsystem,
+		"last_handshake_timestamp_seconds",
+		"Unix timestamp of the last WireGuard handshake with the peer.",
+		peerLabels,
+	)
+	peerReceiveBytesDesc = newDesc(
+		peersSubsystem,
+		"receive_bytes_total",
+		"Bytes received from the peer since tailscaled started.",
+		peerLabels,
+	)
+	peerTransmitBytesDesc = newDesc(
+		peersSubsystem,
+		"transmit_bytes_total",
+		"Bytes sent to the peer since tailscaled started.",
+		peerLabels,
+	)
+	peersDesc = newDesc(
+		"",
+		"peers",
+		"Number of peers of the local node by connection type.",
+		[]string{"connection"},
+	)
+)
+
+// LocalPeersCollector exports the connectivity to every peer from the point
+// of view of the local node.
+type LocalPeersCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector("peers", NewLocalPeersCollector)
+}
+
+func NewLocalPeersCollector(config collectorConfig) (Collector, error) {
+	return &LocalPeersCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c LocalPeersCollector) Update(
+	ctx context.Context,
+	client LocalClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting local peer metrics")
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting tailscaled status", "error", err.Error())
+		return err
+	}
+
+	connections := map[string]int{
+		connectionDirect:    0,
+		connectionPeerRelay: 0,
+		connectionDERP:      0,
+		connectionNone:      0,
+	}
+
+	for _, peer := range status.Peer {
+		labels := []string{string(peer.ID), peerName(peer)}
+
+		ch <- prometheus.MustNewConstMetric(
+			peerInfoDesc, prometheus.GaugeValue, 1,
+			append(labels, peer.HostName, dnsName(peer), peer.OS)...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			peerOnlineDesc, prometheus.GaugeValue, boolAsFloat(peer.Online),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			peerActiveDesc, prometheus.GaugeValue, boolAsFloat(peer.Active),
+			labels...,
+		)
+
+		connection := peerConnection(peer)
+		connections[connection]++
+		for _, candidate := range []string{connectionDirect, connectionPeerRelay, connectionDERP, connectionNone} {
+			ch <- prometheus.MustNewConstMetric(
+				peerConnectionDesc, prometheus.GaugeValue, boolAsFloat(candidate == connection),
+				append(labels, candidate)...,
+			)
+		}
+
+		if peer.Relay != "" {
+			ch <- prometheus.MustNewConstMetric(
+				peerDERPRegionDesc, prometheus.GaugeValue, 1,
+				append(labels, peer.Relay)...,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			peerEndpointsDesc, prometheus.GaugeValue, float64(len(peer.Addrs)),
+			labels...,
+		)
+
+		switch connection {
+		case connectionDirect:
+			ch <- prometheus.MustNewConstMetric(
+				peerCurrentEndpointDesc, prometheus.GaugeValue, 1,
+				append(labels, peer.CurAddr)...,
+			)
+		case connectionPeerRelay:
+			ch <- prometheus.MustNewConstMetric(
+				peerCurrentEndpointDesc, prometheus.GaugeValue, 1,
+				append(labels, peer.PeerRelay)...,
+			)
+		}
+
+		if !peer.LastHandshake.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				peerLastHandshakeDesc, prometheus.GaugeValue, float64(peer.LastHandshake.Unix()),
+				labels...,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			peerReceiveBytesDesc, prometheus.CounterValue, float64(peer.RxBytes),
+			labels...,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			peerTransmitBytesDesc, prometheus.CounterValue, float64(peer.TxBytes),
+			labels...,
+		)
+	}
+
+	for connection, count := range connections {
+		ch <- prometheus.MustNewConstMetric(
+			peersDesc, prometheus.GaugeValue, float64(count),
+			connection,
+		)
+	}
+
+	return nil
+}
+
+// peerConnection returns how traffic to the peer currently flows. Idle peers
+// without a known path are reported as none.
+func peerConnection(peer *ipnstate.PeerStatus) string {
+	switch {
+	case peer.CurAddr != "":
+		return connectionDirect
+	case peer.PeerRelay != "":
+		return connectionPeerRelay
+	case peer.Active && peer.Relay != "":
+		return connectionDERP
+	default:
+		return connectionNone
+	}
+}
+
+// peerName returns the MagicDNS short name of the peer, falling back to its
+// hostname.
+func peerName(peer *ipnstate.PeerStatus) string {
+	if name, _, _ := strings.Cut(peer.DNSName, "."); name != "" {
+		return name
+	}
+	return peer.HostName
+}
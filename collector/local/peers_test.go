@@ -0,0 +1,105 @@
+package local
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+)
+
+func TestLocalPeersCollector_Update(t *testing.T) {
+	socket := newFakeLocalAPI(t, &ipnstate.Status{
+		BackendState: "Running",
+		Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+			key.NewNode().Public(): {
+				ID:            "n1",
+				HostName:      "Alice's Laptop",
+				DNSName:       "laptop.example.ts.net.",
+				OS:            "macOS",
+				Addrs:         []string{"192.0.2.1:41641", "10.0.0.2:41641"},
+				CurAddr:       "192.0.2.1:41641",
+				Relay:         "fra",
+				RxBytes:       2048,
+				TxBytes:       1024,
+				LastHandshake: time.Unix(1700000000, 0),
+				Online:        true,
+				Active:        true,
+			},
+			key.NewNode().Public(): {
+				ID:       "n2",
+				HostName: "router",
+				DNSName:  "router.example.ts.net.",
+				OS:       "linux",
+				Relay:    "nyc",
+				Online:   true,
+				Active:   true,
+			},
+			key.NewNode().Public(): {
+				ID:       "n3",
+				HostName: "phone",
+				OS:       "iOS",
+				Relay:    "fra",
+			},
+		},
+	}, "{}")
+
+	collector := &LocalPeersCollector{log: slog.Default()}
+	metrics := collectFromCollector(t, collector, NewLocalAPIClient(socket))
+
+	expected := `
+# HELP tailscale_local_peer_connection How the local node reaches the peer: direct, peer_relay, derp or none, 1 for the current path.
+# TYPE tailscale_local_peer_connection gauge
+tailscale_local_peer_connection{connection="derp",peer="laptop",peer_id="n1"} 0
+tailscale_local_peer_connection{connection="derp",peer="phone",peer_id="n3"} 0
+tailscale_local_peer_connection{connection="derp",peer="router",peer_id="n2"} 1
+tailscale_local_peer_connection{connection="direct",peer="laptop",peer_id="n1"} 1
+tailscale_local_peer_connection{connection="direct",peer="phone",peer_id="n3"} 0
+tailscale_local_peer_connection{connection="direct",peer="router",peer_id="n2"} 0
+tailscale_local_peer_connection{connection="none",peer="laptop",peer_id="n1"} 0
+tailscale_local_peer_connection{connection="none",peer="phone",peer_id="n3"} 1
+tailscale_local_peer_connection{connection="none",peer="router",peer_id="n2"} 0
+tailscale_local_peer_connection{connection="peer_relay",peer="laptop",peer_id="n1"} 0
+tailscale_local_peer_connection{connection="peer_relay",peer="phone",peer_id="n3"} 0
+tailscale_local_peer_connection{connection="peer_relay",peer="router",peer_id="n2"} 0
+# HELP tailscale_local_peer_current_endpoint_info Endpoint of the current direct or peer relay path to the peer.
+# TYPE tailscale_local_peer_current_endpoint_info gauge
+tailscale_local_peer_current_endpoint_info{endpoint="192.0.2.1:41641",peer="laptop",peer_id="n1"} 1
+# HELP tailscale_local_peer_endpoints Number of candidate endpoints known for the peer.
+# TYPE tailscale_local_peer_endpoints gauge
+tailscale_local_peer_endpoints{peer="laptop",peer_id="n1"} 2
+tailscale_local_peer_endpoints{peer="phone",peer_id="n3"} 0
+tailscale_local_peer_endpoints{peer="router",peer_id="n2"} 0
+# HELP tailscale_local_peer_last_handshake_timestamp_seconds Unix timestamp of the last WireGuard handshake with the peer.
+# TYPE tailscale_local_peer_last_handshake_timestamp_seconds gauge
+tailscale_local_peer_last_handshake_timestamp_seconds{peer="laptop",peer_id="n1"} 1.7e+09
+# HELP tailscale_local_peer_receive_bytes_total Bytes received from the peer since tailscaled started.
+# TYPE tailscale_local_peer_receive_bytes_total counter
+tailscale_local_peer_receive_bytes_total{peer="laptop",peer_id="n1"} 2048
+tailscale_local_peer_receive_bytes_total{peer="phone",peer_id="n3"} 0
+tailscale_local_peer_receive_bytes_total{peer="router",peer_id="n2"} 0
+# HELP tailscale_local_peers Number of peers of the local node by connection type.
+# TYPE tailscale_local_peers gauge
+tailscale_local_peers{connection="derp"} 1
+tailscale_local_peers{connection="direct"} 1
+tailscale_local_peers{connection="none"} 1
+tailscale_local_peers{connection="peer_relay"} 0
+`
+
+	err := testutil.CollectAndCompare(
+		&TestMetricCollector{metrics: metrics},
+		strings.NewReader(expected),
+		"tailscale_local_peer_connection",
+		"tailscale_local_peer_current_endpoint_info",
+		"tailscale_local_peer_endpoints",
+		"tailscale_local_peer_last_handshake_timestamp_seconds",
+		"tailscale_local_peer_receive_bytes_total",
+		"tailscale_local_peers",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,264 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/views"
+)
+
+const probeSubsystem = "probe"
+
+const (
+	ProbeTypeDisco = "disco"
+	ProbeTypeTSMP  = "tsmp"
+	ProbeTypeICMP  = "icmp"
+	ProbeTypeTCP   = "tcp"
+
+	defaultProbeTimeout = 5 * time.Second
+	// probeConcurrency is the number of peers probed at once, so that wide
+	// selections do not ping the whole tailnet at the same time.
+	probeConcurrency = 16
+)
+
+var probePingTypes = map[string]tailcfg.PingType{
+	ProbeTypeDisco: tailcfg.PingDisco,
+	ProbeTypeTSMP:  tailcfg.PingTSMP,
+	ProbeTypeICMP:  tailcfg.PingICMP,
+}
+
+var (
+	probeSuccessDesc = newDesc(
+		probeSubsystem,
+		"success",
+		"Whether the probe of the peer succeeded.",
+		append(peerLabels, "type"),
+	)
+	probeRTTDesc = newDesc(
+		probeSubsystem,
+		"rtt_seconds",
+		"Round trip time of the probe of the peer.",
+		append(peerLabels, "type"),
+	)
+	probePathDesc = newDesc(
+		probeSubsystem,
+		"path_info",
+		"Path the probe of the peer took: direct, peer_relay or derp with its region. Only reported for disco probes.",
+		append(peerLabels, "type", "path", "derp_region"),
+	)
+)
+
+// LocalProbeCollector pings selected peers from the local node to check that
+// they can actually be reached, which the control plane does not report.
+type LocalProbeCollector struct {
+	log      *slog.Logger
+	tags     []string
+	users    []string
+	names    []string
+	probe    string
+	pingType tailcfg.PingType
+	port     uint16
+	timeout  time.Duration
+}
+
+func init() {
+	registerCollector(probeSubsystem, NewLocalProbeCollector)
+}
+
+// NewLocalProbeCollector creates the probe collector. It is only enabled when
+// peers are selected by tag, user or name.
+func NewLocalProbeCollector(config collectorConfig) (Collector, error) {
+	if len(config.ProbeTags) == 0 && len(config.ProbeUsers) == 0 && len(config.ProbeNames) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range config.ProbeNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid probe name pattern %q: %w", pattern, err)
+		}
+	}
+
+	probe := strings.ToLower(config.ProbeType)
+	if probe == "" {
+		probe = ProbeTypeDisco
+	}
+	pingType, ok := probePingTypes[probe]
+	if !ok && probe != ProbeTypeTCP {
+		return nil, fmt.Errorf("invalid probe type %q", config.ProbeType)
+	}
+	if probe == ProbeTypeTCP && (config.ProbePort <= 0 || config.ProbePort > 65535) {
+		return nil, fmt.Errorf("invalid TCP probe port %d", config.ProbePort)
+	}
+
+	timeout := config.ProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+
+	return &LocalProbeCollector{
+		log:      config.logger,
+		tags:     config.ProbeTags,
+		users:    config.ProbeUsers,
+		names:    config.ProbeNames,
+		probe:    probe,
+		pingType: pingType,
+		port:     uint16(config.ProbePort),
+		timeout:  timeout,
+	}, nil
+}
+
+func (c LocalProbeCollector) Update(
+	ctx context.Context,
+	client LocalClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting local probe metrics")
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting tailscaled status", "error", err.Error())
+		return err
+	}
+
+	group := errgroup.Group{}
+	group.SetLimit(probeConcurrency)
+	for _, peer := range status.Peer {
+		if !c.selects(status, peer) {
+			continue
+		}
+		ip, ok := probeAddr(peer)
+		if !ok {
+			c.log.DebugContext(ctx, "Skipping probe of peer without address", "peer", peerName(peer))
+			continue
+		}
+
+		group.Go(func() error {
+			labels := []string{string(peer.ID), peerName(peer), c.probe}
+			rtt, result, err := c.run(ctx, client, ip)
+			if err != nil {
+				c.log.WarnContext(ctx, "Probe failed",
+					"peer", peerName(peer),
+					"type", c.probe,
+					"error", err.Error(),
+				)
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				probeSuccessDesc, prometheus.GaugeValue, boolAsFloat(err == nil),
+				labels...,
+			)
+			if err != nil {
+				return nil
+			}
+			ch <- prometheus.MustNewConstMetric(
+				probeRTTDesc, prometheus.GaugeValue, rtt.Seconds(),
+				labels...,
+			)
+			// Only disco pings report the path, TSMP and ICMP pings
+			// leave it unset.
+			if c.probe == ProbeTypeDisco {
+				probePath, region := pingPath(result)
+				ch <- prometheus.MustNewConstMetric(
+					probePathDesc, prometheus.GaugeValue, 1,
+					append(labels, probePath, region)...,
+				)
+			}
+			return nil
+		})
+	}
+	// Failed probes are reported as metrics, not errors.
+	_ = group.Wait()
+
+	return nil
+}
+
+// run probes ip and returns the round trip time. The ping result is nil for
+// TCP probes.
+func (c LocalProbeCollector) run(
+	ctx context.Context,
+	client LocalClient,
+	ip netip.Addr,
+) (time.Duration, *ipnstate.PingResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if c.probe == ProbeTypeTCP {
+		begin := time.Now()
+		conn, err := client.DialTCP(ctx, ip.String(), c.port)
+		if err != nil {
+			return 0, nil, err
+		}
+		rtt := time.Since(begin)
+		_ = conn.Close()
+		return rtt, nil, nil
+	}
+
+	result, err := client.Ping(ctx, ip, c.pingType)
+	if err != nil {
+		return 0, nil, err
+	}
+	if result.Err != "" {
+		return 0, nil, errors.New(result.Err)
+	}
+	return time.Duration(result.LatencySeconds * float64(time.Second)), result, nil
+}
+
+// selects reports whether the peer has one of the tags, is owned by one of the
+// users or matches one of the name patterns.
+func (c LocalProbeCollector) selects(status *ipnstate.Status, peer *ipnstate.PeerStatus) bool {
+	if peer.Tags != nil && slices.ContainsFunc(c.tags, func(tag string) bool {
+		return views.SliceContains(*peer.Tags, tag)
+	}) {
+		return true
+	}
+	if len(c.users) > 0 {
+		if user, ok := status.User[peer.UserID]; ok && slices.Contains(c.users, user.LoginName) {
+			return true
+		}
+	}
+	for _, pattern := range c.names {
+		for _, name := range []string{peerName(peer), peer.HostName} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// probeAddr returns the Tailscale IP to probe, preferring IPv4.
+func probeAddr(peer *ipnstate.PeerStatus) (netip.Addr, bool) {
+	for _, ip := range peer.TailscaleIPs {
+		if ip.Is4() {
+			return ip, true
+		}
+	}
+	if len(peer.TailscaleIPs) > 0 {
+		return peer.TailscaleIPs[0], true
+	}
+	return netip.Addr{}, false
+}
+
+// pingPath returns how a ping reached the peer and the DERP region code when
+// it was relayed through DERP.
+func pingPath(result *ipnstate.PingResult) (string, string) {
+	switch {
+	case result.Endpoint != "":
+		return connectionDirect, ""
+	case result.PeerRelay != "":
+		return connectionPeerRelay, ""
+	default:
+		return connectionDERP, result.DERPRegionCode
+	}
+}
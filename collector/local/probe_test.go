@@ -0,0 +1,221 @@
+package local
+
+import (
+	"errors"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+	"tailscale.com/types/views"
+)
+
+func probeTestStatus() *ipnstate.Status {
+	dbTags := views.SliceOf([]string{"tag:db"})
+	routerTags := views.SliceOf([]string{"tag:router", "tag:prod"})
+	return &ipnstate.Status{
+		BackendState: "Running",
+		User: map[tailcfg.UserID]tailcfg.UserProfile{
+			1: {ID: 1, LoginName: "alice@example.com"},
+		},
+		Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+			key.NewNode().Public(): {
+				ID:           "n1",
+				DNSName:      "db.example.ts.net.",
+				Tags:         &dbTags,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("fd7a:115c:a1e0::1"), netip.MustParseAddr("100.64.0.1")},
+			},
+			key.NewNode().Public(): {
+				ID:           "n2",
+				DNSName:      "subnet-router.example.ts.net.",
+				Tags:         &routerTags,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.2")},
+			},
+			key.NewNode().Public(): {
+				ID:           "n3",
+				DNSName:      "laptop.example.ts.net.",
+				UserID:       1,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.3")},
+			},
+			key.NewNode().Public(): {
+				ID:           "n4",
+				DNSName:      "phone.example.ts.net.",
+				UserID:       2,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.4")},
+			},
+		},
+	}
+}
+
+func TestLocalProbeCollector_Update(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		client          *mockLocalClient
+		metricNames     []string
+		expectedMetrics string
+	}{
+		{
+			name: "disco pings by tag, user and name",
+			config: Config{
+				ProbeTags:  []string{"tag:db"},
+				ProbeUsers: []string{"alice@example.com"},
+				ProbeNames: []string{"subnet-*"},
+			},
+			client: &mockLocalClient{
+				status: probeTestStatus(),
+				pingResults: map[netip.Addr]*ipnstate.PingResult{
+					netip.MustParseAddr("100.64.0.1"): {
+						LatencySeconds: 0.004,
+						Endpoint:       "192.0.2.1:41641",
+					},
+					netip.MustParseAddr("100.64.0.2"): {
+						LatencySeconds: 0.031,
+						DERPRegionID:   4,
+						DERPRegionCode: "fra",
+					},
+					netip.MustParseAddr("100.64.0.3"): {
+						Err: "no matching peer",
+					},
+				},
+			},
+			metricNames: []string{
+				"tailscale_local_probe_success",
+				"tailscale_local_probe_path_info",
+				"tailscale_local_probe_rtt_seconds",
+			},
+			expectedMetrics: `
+# HELP tailscale_local_probe_path_info Path the probe of the peer took: direct, peer_relay or derp with its region. Only reported for disco probes.
+# TYPE tailscale_local_probe_path_info gauge
+tailscale_local_probe_path_info{derp_region="",path="direct",peer="db",peer_id="n1",type="disco"} 1
+tailscale_local_probe_path_info{derp_region="fra",path="derp",peer="subnet-router",peer_id="n2",type="disco"} 1
+# HELP tailscale_local_probe_rtt_seconds Round trip time of the probe of the peer.
+# TYPE tailscale_local_probe_rtt_seconds gauge
+tailscale_local_probe_rtt_seconds{peer="db",peer_id="n1",type="disco"} 0.004
+tailscale_local_probe_rtt_seconds{peer="subnet-router",peer_id="n2",type="disco"} 0.031
+# HELP tailscale_local_probe_success Whether the probe of the peer succeeded.
+# TYPE tailscale_local_probe_success gauge
+tailscale_local_probe_success{peer="db",peer_id="n1",type="disco"} 1
+tailscale_local_probe_success{peer="laptop",peer_id="n3",type="disco"} 0
+tailscale_local_probe_success{peer="subnet-router",peer_id="n2",type="disco"} 1
+`,
+		},
+		{
+			name: "tsmp pings report no path",
+			config: Config{
+				ProbeTags: []string{"tag:db"},
+				ProbeType: "tsmp",
+			},
+			client: &mockLocalClient{
+				status: probeTestStatus(),
+				pingResults: map[netip.Addr]*ipnstate.PingResult{
+					netip.MustParseAddr("100.64.0.1"): {
+						LatencySeconds: 0.006,
+						NodeIP:         "100.64.0.1",
+					},
+				},
+			},
+			metricNames: []string{
+				"tailscale_local_probe_success",
+				"tailscale_local_probe_path_info",
+				"tailscale_local_probe_rtt_seconds",
+			},
+			expectedMetrics: `
+# HELP tailscale_local_probe_rtt_seconds Round trip time of the probe of the peer.
+# TYPE tailscale_local_probe_rtt_seconds gauge
+tailscale_local_probe_rtt_seconds{peer="db",peer_id="n1",type="tsmp"} 0.006
+# HELP tailscale_local_probe_success Whether the probe of the peer succeeded.
+# TYPE tailscale_local_probe_success gauge
+tailscale_local_probe_success{peer="db",peer_id="n1",type="tsmp"} 1
+`,
+		},
+		{
+			name: "tcp probes",
+			config: Config{
+				ProbeTags: []string{"tag:db", "tag:prod"},
+				ProbeType: "TCP",
+				ProbePort: 5432,
+			},
+			client: &mockLocalClient{
+				status: probeTestStatus(),
+				dialErrs: map[string]error{
+					"100.64.0.2": errors.New("connection refused"),
+				},
+			},
+			// The round trip time of TCP probes is measured locally and
+			// not compared.
+			metricNames: []string{
+				"tailscale_local_probe_success",
+				"tailscale_local_probe_path_info",
+			},
+			expectedMetrics: `
+# HELP tailscale_local_probe_success Whether the probe of the peer succeeded.
+# TYPE tailscale_local_probe_success gauge
+tailscale_local_probe_success{peer="db",peer_id="n1",type="tcp"} 1
+tailscale_local_probe_success{peer="subnet-router",peer_id="n2",type="tcp"} 0
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewLocalProbeCollector(collectorConfig{
+				logger: slog.Default(),
+				Config: tt.config,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics := collectFromCollector(t, collector, tt.client)
+			err = testutil.CollectAndCompare(
+				&TestMetricCollector{metrics: metrics},
+				strings.NewReader(tt.expectedMetrics),
+				tt.metricNames...,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewLocalProbeCollector(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		expectNil   bool
+		expectError bool
+	}{
+		{name: "disabled without selection", expectNil: true},
+		{name: "invalid type", config: Config{ProbeTags: []string{"tag:db"}, ProbeType: "udp"}, expectError: true},
+		{name: "tcp without port", config: Config{ProbeTags: []string{"tag:db"}, ProbeType: "tcp"}, expectError: true},
+		{name: "invalid pattern", config: Config{ProbeNames: []string{"["}}, expectError: true},
+		{name: "tsmp", config: Config{ProbeUsers: []string{"alice@example.com"}, ProbeType: "tsmp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewLocalProbeCollector(collectorConfig{
+				logger: slog.Default(),
+				Config: tt.config,
+			})
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (collector == nil) != tt.expectNil {
+				t.Fatalf("collector = %v, expected nil %v", collector, tt.expectNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package local
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+var (
+	scrapeCacheHitsDesc = newDesc(
+		"scrape",
+		"cache_hits_total",
+		"tailscale_exporter: Number of LocalAPI reads served from the per-scrape snapshot.",
+		[]string{"resource"},
+	)
+	scrapeCacheMissesDesc = newDesc(
+		"scrape",
+		"cache_misses_total",
+		"tailscale_exporter: Number of LocalAPI reads that were fetched from tailscaled.",
+		[]string{"resource"},
+	)
+)
+
+// snapshotClient serves the LocalAPI reads of a LocalClient from a per-scrape
+// cache. Probes are passed through.
+type snapshotClient struct {
+	client LocalClient
+	cache  *snapshot.Cache
+}
+
+func newSnapshotClient(client LocalClient, stats *snapshot.Stats) *snapshotClient {
+	return &snapshotClient{
+		client: client,
+		cache:  snapshot.New(stats),
+	}
+}
+
+func (s *snapshotClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	return snapshot.Fetch(s.cache, "status", func() (*ipnstate.Status, error) {
+		return s.client.Status(ctx)
+	})
+}
+
+func (s *snapshotClient) NetInfo(ctx context.Context) (*tailcfg.NetInfo, error) {
+	return snapshot.Fetch(s.cache, "netinfo", func() (*tailcfg.NetInfo, error) {
+		return s.client.NetInfo(ctx)
+	})
+}
+
+func (s *snapshotClient) Ping(
+	ctx context.Context,
+	ip netip.Addr,
+	pingType tailcfg.PingType,
+) (*ipnstate.PingResult, error) {
+	return s.client.Ping(ctx, ip, pingType)
+}
+
+func (s *snapshotClient) DialTCP(ctx context.Context, host string, port uint16) (net.Conn, error) {
+	return s.client.DialTCP(ctx, host, port)
+}
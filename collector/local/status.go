@@ -0,0 +1,135 @@
+package local
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+)
+
+const statusSubsystem = ""
+
+var (
+	localInfoDesc = newDesc(
+		statusSubsystem,
+		"info",
+		"Information about the local tailscaled node.",
+		[]string{"version", "hostname", "dns_name", "os", "tailnet"},
+	)
+	localBackendStateDesc = newDesc(
+		statusSubsystem,
+		"backend_state",
+		"Current backend state of tailscaled, 1 for the active state.",
+		[]string{"state"},
+	)
+	localHealthMessagesDesc = newDesc(
+		statusSubsystem,
+		"health_messages",
+		"Number of health warnings reported by tailscaled.",
+		nil,
+	)
+	localHomeDERPDesc = newDesc(
+		statusSubsystem,
+		"home_derp_region_info",
+		"DERP region this node uses as its home relay.",
+		[]string{"region"},
+	)
+	localKeyExpiryDesc = newDesc(
+		statusSubsystem,
+		"key_expiry_timestamp_seconds",
+		"Unix timestamp when the node key of this node expires.",
+		nil,
+	)
+)
+
+var backendStates = []ipn.State{
+	ipn.NoState,
+	ipn.InUseOtherUser,
+	ipn.NeedsLogin,
+	ipn.NeedsMachineAuth,
+	ipn.Stopped,
+	ipn.Starting,
+	ipn.Running,
+}
+
+// LocalStatusCollector exports the state of the local node.
+type LocalStatusCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector("status", NewLocalStatusCollector)
+}
+
+func NewLocalStatusCollector(config collectorConfig) (Collector, error) {
+	return &LocalStatusCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c LocalStatusCollector) Update(
+	ctx context.Context,
+	client LocalClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting local status metrics")
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting tailscaled status", "error", err.Error())
+		return err
+	}
+
+	for _, state := range backendStates {
+		ch <- prometheus.MustNewConstMetric(
+			localBackendStateDesc, prometheus.GaugeValue,
+			boolAsFloat(status.BackendState == state.String()),
+			state.String(),
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		localHealthMessagesDesc, prometheus.GaugeValue, float64(len(status.Health)),
+	)
+
+	var tailnet string
+	if status.CurrentTailnet != nil {
+		tailnet = status.CurrentTailnet.Name
+	}
+
+	self := status.Self
+	if self == nil {
+		ch <- prometheus.MustNewConstMetric(
+			localInfoDesc, prometheus.GaugeValue, 1,
+			status.Version, "", "", "", tailnet,
+		)
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		localInfoDesc, prometheus.GaugeValue, 1,
+		status.Version, self.HostName, dnsName(self), self.OS, tailnet,
+	)
+
+	if self.Relay != "" {
+		ch <- prometheus.MustNewConstMetric(
+			localHomeDERPDesc, prometheus.GaugeValue, 1,
+			self.Relay,
+		)
+	}
+
+	if self.KeyExpiry != nil {
+		ch <- prometheus.MustNewConstMetric(
+			localKeyExpiryDesc, prometheus.GaugeValue, float64(self.KeyExpiry.Unix()),
+		)
+	}
+
+	return nil
+}
+
+func dnsName(peer *ipnstate.PeerStatus) string {
+	return strings.TrimSuffix(peer.DNSName, ".")
+}
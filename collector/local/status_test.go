@@ -0,0 +1,65 @@
+package local
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/ipn/ipnstate"
+)
+
+func TestLocalStatusCollector_Update(t *testing.T) {
+	expiry := time.Unix(1700000000, 0)
+	socket := newFakeLocalAPI(t, &ipnstate.Status{
+		Version:      "1.94.1",
+		BackendState: "NeedsLogin",
+		Health:       []string{"not logged in"},
+		CurrentTailnet: &ipnstate.TailnetStatus{
+			Name: "example.com",
+		},
+		Self: &ipnstate.PeerStatus{
+			HostName:  "exporter",
+			DNSName:   "exporter.example.ts.net.",
+			OS:        "linux",
+			Relay:     "fra",
+			KeyExpiry: &expiry,
+		},
+	}, "{}")
+
+	collector := &LocalStatusCollector{log: slog.Default()}
+	metrics := collectFromCollector(t, collector, NewLocalAPIClient(socket))
+
+	expected := `
+# HELP tailscale_local_backend_state Current backend state of tailscaled, 1 for the active state.
+# TYPE tailscale_local_backend_state gauge
+tailscale_local_backend_state{state="InUseOtherUser"} 0
+tailscale_local_backend_state{state="NeedsLogin"} 1
+tailscale_local_backend_state{state="NeedsMachineAuth"} 0
+tailscale_local_backend_state{state="NoState"} 0
+tailscale_local_backend_state{state="Running"} 0
+tailscale_local_backend_state{state="Starting"} 0
+tailscale_local_backend_state{state="Stopped"} 0
+# HELP tailscale_local_health_messages Number of health warnings reported by tailscaled.
+# TYPE tailscale_local_health_messages gauge
+tailscale_local_health_messages 1
+# HELP tailscale_local_home_derp_region_info DERP region this node uses as its home relay.
+# TYPE tailscale_local_home_derp_region_info gauge
+tailscale_local_home_derp_region_info{region="fra"} 1
+# HELP tailscale_local_info Information about the local tailscaled node.
+# TYPE tailscale_local_info gauge
+tailscale_local_info{dns_name="exporter.example.ts.net",hostname="exporter",os="linux",tailnet="example.com",version="1.94.1"} 1
+# HELP tailscale_local_key_expiry_timestamp_seconds Unix timestamp when the node key of this node expires.
+# TYPE tailscale_local_key_expiry_timestamp_seconds gauge
+tailscale_local_key_expiry_timestamp_seconds 1.7e+09
+`
+
+	err := testutil.CollectAndCompare(
+		&TestMetricCollector{metrics: metrics},
+		strings.NewReader(expected),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
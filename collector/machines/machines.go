@@ -0,0 +1,240 @@
+// Package machines federates the client metrics of tailnet devices.
+package machines
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+const (
+	// Path is where the federated machine metrics are served.
+	Path = "/machine-metrics"
+
+	machineLabel = "tailscale_machine"
+	tailnetLabel = "tailnet"
+)
+
+// Config configures how machines are scraped.
+type Config struct {
+	// Port is the port tailscaled serves client metrics on.
+	Port int
+	// Concurrency bounds the number of machines scraped at once.
+	Concurrency int
+	// Timeout bounds the scrape of a single machine.
+	Timeout time.Duration
+	// Client scrapes the machines, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// Gatherer scrapes the client metrics of every online device of its sources
+// and merges them, labelled with tailscale_machine and tailnet.
+type Gatherer struct {
+	log     *slog.Logger
+	sources []sd.Source
+	config  Config
+}
+
+// NewGatherer returns a gatherer for the devices of sources.
+func NewGatherer(logger *slog.Logger, config Config, sources ...sd.Source) *Gatherer {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &Gatherer{
+		log:     logger,
+		sources: sources,
+		config:  config,
+	}
+}
+
+type machineResult struct {
+	device   sd.Device
+	families map[string]*dto.MetricFamily
+	duration time.Duration
+	err      error
+}
+
+// Gather implements prometheus.Gatherer. Machines that cannot be scraped are
+// reported through tailscale_machine_scrape_success instead of failing the
+// whole request.
+func (g *Gatherer) Gather() ([]*dto.MetricFamily, error) {
+	ctx := context.Background()
+
+	var devices []sd.Device
+	for _, source := range g.sources {
+		listed, err := source(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list machines: %w", err)
+		}
+		for _, device := range listed {
+			if device.Online && (device.IPv4 != "" || device.IPv6 != "") {
+				devices = append(devices, device)
+			}
+		}
+	}
+
+	results := make([]machineResult, len(devices))
+	group := errgroup.Group{}
+	group.SetLimit(g.config.Concurrency)
+	for i, device := range devices {
+		group.Go(func() error {
+			begin := time.Now()
+			families, err := g.scrape(ctx, device)
+			results[i] = machineResult{
+				device:   device,
+				families: families,
+				duration: time.Since(begin),
+				err:      err,
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return mergeResults(g.log, results), nil
+}
+
+func (g *Gatherer) scrape(ctx context.Context, device sd.Device) (map[string]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.config.Timeout)
+	defer cancel()
+
+	host := device.IPv4
+	if host == "" {
+		host = device.IPv6
+	}
+	url := "http://" + net.JoinHostPort(host, strconv.Itoa(g.config.Port)) + "/metrics"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// mergeResults labels the metrics of every machine and merges families of the
+// same name, keeping the help and type of the first machine.
+func mergeResults(logger *slog.Logger, results []machineResult) []*dto.MetricFamily {
+	merged := make(map[string]*dto.MetricFamily)
+	success := &dto.MetricFamily{
+		Name: proto.String("tailscale_machine_scrape_success"),
+		Help: proto.String("Whether the client metrics of the machine were scraped."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	duration := &dto.MetricFamily{
+		Name: proto.String("tailscale_machine_scrape_duration_seconds"),
+		Help: proto.String("Duration of the client metrics scrape of the machine."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+
+	for _, result := range results {
+		// Label pairs are kept sorted by name as in the exposition format.
+		labels := []*dto.LabelPair{
+			{Name: proto.String(tailnetLabel), Value: proto.String(result.device.Tailnet)},
+			{Name: proto.String(machineLabel), Value: proto.String(result.device.Machine)},
+		}
+
+		value := 1.0
+		if result.err != nil {
+			logger.Debug("Error scraping machine metrics",
+				"machine", result.device.Machine, "error", result.err)
+			value = 0
+		}
+		success.Metric = append(success.Metric, gaugeMetric(labels, value))
+		duration.Metric = append(duration.Metric, gaugeMetric(labels, result.duration.Seconds()))
+
+		for name, family := range result.families {
+			for _, metric := range family.GetMetric() {
+				metric.Label = withLabels(metric.GetLabel(), labels)
+			}
+
+			existing, ok := merged[name]
+			if !ok {
+				merged[name] = family
+				continue
+			}
+			if existing.GetType() != family.GetType() {
+				logger.Debug("Skipping machine metric with conflicting type",
+					"machine", result.device.Machine, "metric", name)
+				continue
+			}
+			existing.Metric = append(existing.Metric, family.GetMetric()...)
+		}
+	}
+
+	families := []*dto.MetricFamily{success, duration}
+	for _, name := range slices.Sorted(maps.Keys(merged)) {
+		families = append(families, merged[name])
+	}
+	for _, family := range families {
+		slices.SortStableFunc(family.Metric, compareMetrics)
+	}
+	return families
+}
+
+// compareMetrics orders metrics by their label values.
+func compareMetrics(a, b *dto.Metric) int {
+	for i := range min(len(a.GetLabel()), len(b.GetLabel())) {
+		la, lb := a.GetLabel()[i], b.GetLabel()[i]
+		if c := strings.Compare(la.GetName(), lb.GetName()); c != 0 {
+			return c
+		}
+		if c := strings.Compare(la.GetValue(), lb.GetValue()); c != 0 {
+			return c
+		}
+	}
+	return len(a.GetLabel()) - len(b.GetLabel())
+}
+
+func gaugeMetric(labels []*dto.LabelPair, value float64) *dto.Metric {
+	return &dto.Metric{
+		Label: labels,
+		Gauge: &dto.Gauge{Value: proto.Float64(value)},
+	}
+}
+
+// withLabels sets the injected labels on a metric, replacing labels of the
+// same name, and keeps the pairs sorted by name.
+func withLabels(existing, injected []*dto.LabelPair) []*dto.LabelPair {
+	result := make([]*dto.LabelPair, 0, len(existing)+len(injected))
+	for _, pair := range existing {
+		if !slices.ContainsFunc(injected, func(l *dto.LabelPair) bool {
+			return l.GetName() == pair.GetName()
+		}) {
+			result = append(result, pair)
+		}
+	}
+	result = append(result, injected...)
+	slices.SortFunc(result, func(a, b *dto.LabelPair) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	return result
+}
@@ -0,0 +1,143 @@
+package machines
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+const clientMetrics = `# HELP tailscaled_inbound_bytes_total Counts the number of bytes received from other peers
+# TYPE tailscaled_inbound_bytes_total counter
+tailscaled_inbound_bytes_total{path="direct_ipv4"} 1024
+tailscaled_inbound_bytes_total{path="derp"} 16
+# HELP tailscaled_health_messages Number of health messages broken down by type.
+# TYPE tailscaled_health_messages gauge
+tailscaled_health_messages{type="warning",tailscale_machine="spoofed"} 1
+`
+
+func newClientServer(t *testing.T) int {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, clientMetrics)
+	}))
+	t.Cleanup(server.Close)
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestGatherer(t *testing.T) {
+	port := newClientServer(t)
+
+	source := func(context.Context) ([]sd.Device, error) {
+		return []sd.Device{
+			{Machine: "laptop", Tailnet: "example.com", Online: true, IPv4: "127.0.0.1"},
+			{Machine: "offline", Tailnet: "example.com", Online: false, IPv4: "127.0.0.1"},
+			{Machine: "no-address", Tailnet: "example.com", Online: true},
+		}, nil
+	}
+	nodes := func(context.Context) ([]sd.Device, error) {
+		return []sd.Device{
+			{Machine: "server", Online: true, IPv6: "::1"},
+		}, nil
+	}
+
+	gatherer := NewGatherer(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Config{Port: port, Concurrency: 2, Timeout: time.Second},
+		source,
+		nodes,
+	)
+
+	expected := `
+# HELP tailscale_machine_scrape_success Whether the client metrics of the machine were scraped.
+# TYPE tailscale_machine_scrape_success gauge
+tailscale_machine_scrape_success{tailnet="",tailscale_machine="server"} 0
+tailscale_machine_scrape_success{tailnet="example.com",tailscale_machine="laptop"} 1
+# HELP tailscaled_health_messages Number of health messages broken down by type.
+# TYPE tailscaled_health_messages gauge
+tailscaled_health_messages{tailnet="example.com",tailscale_machine="laptop",type="warning"} 1
+# HELP tailscaled_inbound_bytes_total Counts the number of bytes received from other peers
+# TYPE tailscaled_inbound_bytes_total counter
+tailscaled_inbound_bytes_total{path="derp",tailnet="example.com",tailscale_machine="laptop"} 16
+tailscaled_inbound_bytes_total{path="direct_ipv4",tailnet="example.com",tailscale_machine="laptop"} 1024
+`
+
+	// The test server only listens on IPv4, so the IPv6 node fails to scrape.
+	err := testutil.GatherAndCompare(
+		gatherer,
+		strings.NewReader(expected),
+		"tailscale_machine_scrape_success",
+		"tailscaled_health_messages",
+		"tailscaled_inbound_bytes_total",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGathererTimeout(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(block) })
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	source := func(context.Context) ([]sd.Device, error) {
+		return []sd.Device{
+			{Machine: "slow", Tailnet: "example.com", Online: true, IPv4: "127.0.0.1"},
+		}, nil
+	}
+	gatherer := NewGatherer(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Config{Port: p, Concurrency: 1, Timeout: 50 * time.Millisecond},
+		source,
+	)
+
+	begin := time.Now()
+	expected := `
+# HELP tailscale_machine_scrape_success Whether the client metrics of the machine were scraped.
+# TYPE tailscale_machine_scrape_success gauge
+tailscale_machine_scrape_success{tailnet="example.com",tailscale_machine="slow"} 0
+`
+	err := testutil.GatherAndCompare(
+		gatherer,
+		strings.NewReader(expected),
+		"tailscale_machine_scrape_success",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("gather took %s, expected the scrape to time out", elapsed)
+	}
+}
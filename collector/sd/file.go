@@ -0,0 +1,211 @@
+package sd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/fileutil"
+)
+
+const (
+	FileFormatJSON = "json"
+	FileFormatYAML = "yaml"
+)
+
+// Store holds the devices most recently listed by the collectors, keyed by
+// source, so they can be written out without listing them again.
+type Store struct {
+	mtx     sync.Mutex
+	sources map[string]storedDevices
+}
+
+type storedDevices struct {
+	devices   []Device
+	updatedAt time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		sources: make(map[string]storedDevices),
+	}
+}
+
+// Set replaces the devices of source.
+func (s *Store) Set(source string, devices []Device) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sources[source] = storedDevices{devices: devices, updatedAt: time.Now()}
+}
+
+// Devices returns the devices of every source, ordered by source, and whether
+// any source has been listed yet.
+func (s *Store) Devices() ([]Device, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var devices []Device
+	for _, source := range slices.Sorted(maps.Keys(s.sources)) {
+		devices = append(devices, s.sources[source].devices...)
+	}
+	return devices, len(s.sources) > 0
+}
+
+// UpdatedAt returns when the devices of source were last set, or the zero
+// time when they never were.
+func (s *Store) UpdatedAt(source string) time.Time {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.sources[source].updatedAt
+}
+
+// FileWriter periodically writes the devices of a Store as a file_sd file.
+// Sources whose devices in the Store are outdated, e.g. because /metrics is
+// not scraped, are listed directly.
+type FileWriter struct {
+	log     *slog.Logger
+	store   *Store
+	sources map[string]Source
+	path    string
+	format  string
+	port    int
+	filter  Filter
+	labels  []string
+
+	mtx  sync.Mutex
+	last []byte
+}
+
+// NewFileWriter returns a writer of the devices of store to path. Sources,
+// keyed by the same names as the store, list the devices directly. Format is
+// FileFormatJSON or FileFormatYAML. When labels is not empty only those
+// target labels are written.
+func NewFileWriter(
+	logger *slog.Logger,
+	store *Store,
+	sources map[string]Source,
+	path, format string,
+	port int,
+	filter Filter,
+	labels []string,
+) (*FileWriter, error) {
+	switch format {
+	case FileFormatJSON, FileFormatYAML:
+	default:
+		return nil, fmt.Errorf("invalid service discovery file format %q, must be json or yaml", format)
+	}
+
+	return &FileWriter{
+		log:     logger,
+		store:   store,
+		sources: sources,
+		path:    path,
+		format:  format,
+		port:    port,
+		filter:  filter,
+		labels:  labels,
+	}, nil
+}
+
+// Run writes the file every interval until ctx is done. Sources not stored
+// within the last interval are listed first.
+func (w *FileWriter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, interval)
+		w.list(refreshCtx, interval)
+		cancel()
+		if err := w.Write(); err != nil {
+			w.log.ErrorContext(ctx, "Error writing service discovery file", "path", w.path, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh lists the devices of every source and writes the file, e.g. after
+// devices were added or removed.
+func (w *FileWriter) Refresh(ctx context.Context) {
+	w.list(ctx, 0)
+	if err := w.Write(); err != nil {
+		w.log.ErrorContext(ctx, "Error writing service discovery file", "path", w.path, "error", err)
+	}
+}
+
+// list lists the devices of the sources not stored within maxAge into the
+// store. A source that fails keeps the devices stored last, so that API
+// errors do not empty the file.
+func (w *FileWriter) list(ctx context.Context, maxAge time.Duration) {
+	for name, source := range w.sources {
+		if maxAge > 0 && time.Since(w.store.UpdatedAt(name)) < maxAge {
+			continue
+		}
+		devices, err := source(ctx)
+		if err != nil {
+			w.log.ErrorContext(ctx, "Error listing service discovery targets", "source", name, "error", err)
+			continue
+		}
+		w.store.Set(name, devices)
+	}
+}
+
+// Write renders the stored devices and replaces the file atomically. Nothing
+// is written until a source has been listed or when the content did not
+// change.
+func (w *FileWriter) Write() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	devices, ok := w.store.Devices()
+	if !ok {
+		return nil
+	}
+
+	groups := TargetGroups(devices, w.port, w.filter)
+	if len(w.labels) > 0 {
+		for i := range groups {
+			for name := range groups[i].Labels {
+				if !slices.Contains(w.labels, name) {
+					delete(groups[i].Labels, name)
+				}
+			}
+		}
+	}
+
+	var data []byte
+	var err error
+	switch w.format {
+	case FileFormatYAML:
+		data, err = yaml.Marshal(groups)
+	default:
+		data, err = json.MarshalIndent(groups, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(data, w.last) {
+		return nil
+	}
+	if err := fileutil.WriteFileAtomic(w.path, data); err != nil {
+		return err
+	}
+	w.last = data
+	return nil
+}
@@ -0,0 +1,133 @@
+package sd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWriter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name   string
+		format string
+		labels []string
+		want   string
+	}{
+		{
+			name:   "json with all labels",
+			format: FileFormatJSON,
+			want: `[
+  {
+    "targets": [
+      "100.64.0.1:9100"
+    ],
+    "labels": {
+      "hostname": "alice-laptop",
+      "os": "macOS",
+      "tags": "",
+      "tailnet": "example.com",
+      "tailscale_machine": "laptop",
+      "user": "alice@example.com"
+    }
+  }
+]`,
+		},
+		{
+			name:   "yaml with selected labels",
+			format: FileFormatYAML,
+			labels: []string{"tailscale_machine", "tailnet"},
+			want: `- targets:
+    - 100.64.0.1:9100
+  labels:
+    tailnet: example.com
+    tailscale_machine: laptop
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "targets")
+			store := NewStore()
+			writer, err := NewFileWriter(
+				logger, store, nil, path, tt.format, 9100, Filter{OS: []string{"macos"}}, tt.labels,
+			)
+			if err != nil {
+				t.Fatalf("failed to create writer: %v", err)
+			}
+
+			// Nothing is written before a collector listed devices.
+			if err := writer.Write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatalf("expected no file before devices are listed, got %v", err)
+			}
+
+			store.Set("tailscale", testDevices)
+			if err := writer.Write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestFileWriter_ListOutdatedSources(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store := NewStore()
+	listed := 0
+	listErr := error(nil)
+	sources := map[string]Source{
+		"tailscale": func(context.Context) ([]Device, error) {
+			listed++
+			return testDevices, listErr
+		},
+	}
+	writer, err := NewFileWriter(
+		logger, store, sources, filepath.Join(t.TempDir(), "targets"), FileFormatJSON, DefaultPort, Filter{}, nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+
+	// Devices stored by a recent scrape are not listed again.
+	store.Set("tailscale", testDevices[:1])
+	writer.list(t.Context(), time.Hour)
+	if listed != 0 {
+		t.Fatalf("expected no listing of recently stored devices, got %d", listed)
+	}
+
+	// Without a recent scrape the source is listed directly.
+	writer.list(t.Context(), time.Nanosecond)
+	if devices, _ := store.Devices(); listed != 1 || len(devices) != len(testDevices) {
+		t.Fatalf("expected one listing of %d devices, got %d listings of %d devices", len(testDevices), listed, len(devices))
+	}
+
+	// A failing source keeps the devices stored last.
+	listErr = errors.New("api unavailable")
+	writer.Refresh(t.Context())
+	if devices, _ := store.Devices(); listed != 2 || len(devices) != len(testDevices) {
+		t.Fatalf("expected the stored devices to be kept, got %d devices", len(devices))
+	}
+}
+
+func TestNewFileWriter_InvalidFormat(t *testing.T) {
+	if _, err := NewFileWriter(slog.Default(), NewStore(), nil, "targets", "toml", DefaultPort, Filter{}, nil); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
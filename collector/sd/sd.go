@@ -0,0 +1,155 @@
+// Package sd turns tailnet devices into Prometheus service discovery target
+// groups.
+package sd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPort is the port tailscaled serves client metrics on over the
+	// tailnet.
+	DefaultPort = 5252
+	// DevicesPath serves the Tailscale devices.
+	DevicesPath = "/sd/devices"
+	// NodesPath serves the Headscale nodes.
+	NodesPath = "/sd/nodes"
+)
+
+// Device is a tailnet device or Headscale node that can be scraped.
+type Device struct {
+	// Machine is the MagicDNS short name, exported as tailscale_machine.
+	Machine  string
+	Hostname string
+	OS       string
+	User     string
+	Tags     []string
+	Tailnet  string
+	Online   bool
+	IPv4     string
+	IPv6     string
+}
+
+// TargetGroup is a target group in the http_sd and file_sd format.
+type TargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels"  yaml:"labels"`
+}
+
+// Filter selects the devices that are turned into targets. Empty fields match
+// every device.
+type Filter struct {
+	// Tags matches devices with at least one of the tags.
+	Tags []string
+	// OS matches devices running one of the operating systems, ignoring case.
+	OS []string
+	// Online matches devices by their connection state.
+	Online *bool
+}
+
+// ParseFilter reads a filter from the tag, os and online query parameters.
+func ParseFilter(query url.Values) (Filter, error) {
+	filter := Filter{
+		Tags: query["tag"],
+		OS:   query["os"],
+	}
+	if value := query.Get("online"); value != "" {
+		online, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid online filter %q: %w", value, err)
+		}
+		filter.Online = &online
+	}
+	return filter, nil
+}
+
+// Match reports whether the device passes the filter.
+func (f Filter) Match(device Device) bool {
+	if f.Online != nil && device.Online != *f.Online {
+		return false
+	}
+	if len(f.OS) > 0 && !slices.ContainsFunc(f.OS, func(os string) bool {
+		return strings.EqualFold(os, device.OS)
+	}) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(tag string) bool {
+		return slices.Contains(device.Tags, tag)
+	}) {
+		return false
+	}
+	return true
+}
+
+// TargetGroups returns one target group per matching device, addressed by its
+// Tailscale IP and port. Devices without an address are skipped.
+func TargetGroups(devices []Device, port int, filter Filter) []TargetGroup {
+	groups := make([]TargetGroup, 0, len(devices))
+	for _, device := range devices {
+		if !filter.Match(device) {
+			continue
+		}
+
+		host := device.IPv4
+		if host == "" {
+			host = device.IPv6
+		}
+		if host == "" {
+			continue
+		}
+
+		tags := slices.Clone(device.Tags)
+		slices.Sort(tags)
+		labels := map[string]string{
+			"tailscale_machine": device.Machine,
+			"hostname":          device.Hostname,
+			"os":                device.OS,
+			"user":              device.User,
+			"tags":              strings.Join(tags, ","),
+		}
+		if device.Tailnet != "" {
+			labels["tailnet"] = device.Tailnet
+		}
+
+		groups = append(groups, TargetGroup{
+			Targets: []string{net.JoinHostPort(host, strconv.Itoa(port))},
+			Labels:  labels,
+		})
+	}
+	return groups
+}
+
+// Source lists the devices to discover.
+type Source func(ctx context.Context) ([]Device, error)
+
+// Handler serves the devices of source in the Prometheus http_sd format.
+func Handler(logger *slog.Logger, source Source, port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		devices, err := source(r.Context())
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Error listing service discovery targets", "error", err)
+			http.Error(w, "failed to list devices", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(TargetGroups(devices, port, filter)); err != nil {
+			logger.ErrorContext(r.Context(), "Error writing service discovery response", "error", err)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package sd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDevices = []Device{
+	{
+		Machine:  "laptop",
+		Hostname: "alice-laptop",
+		OS:       "macOS",
+		User:     "alice@example.com",
+		Tailnet:  "example.com",
+		Online:   true,
+		IPv4:     "100.64.0.1",
+	},
+	{
+		Machine:  "router",
+		Hostname: "router",
+		OS:       "linux",
+		User:     "alice@example.com",
+		Tags:     []string{"tag:router", "tag:infra"},
+		Tailnet:  "example.com",
+		Online:   false,
+		IPv4:     "100.64.0.2",
+	},
+	{
+		Machine:  "v6-only",
+		Hostname: "v6-only",
+		OS:       "linux",
+		Tags:     []string{"tag:infra"},
+		Online:   true,
+		IPv6:     "fd7a:115c:a1e0::3",
+	},
+	{
+		Machine: "no-address",
+		OS:      "linux",
+		Online:  true,
+	},
+}
+
+func TestTargetGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "all devices with an address",
+			query: "",
+			want:  []string{"100.64.0.1:5252", "100.64.0.2:5252", "[fd7a:115c:a1e0::3]:5252"},
+		},
+		{
+			name:  "any of the tags",
+			query: "tag=tag:router&tag=tag:missing",
+			want:  []string{"100.64.0.2:5252"},
+		},
+		{
+			name:  "os ignores case",
+			query: "os=LINUX",
+			want:  []string{"100.64.0.2:5252", "[fd7a:115c:a1e0::3]:5252"},
+		},
+		{
+			name:  "online tagged devices",
+			query: "tag=tag:infra&online=true",
+			want:  []string{"[fd7a:115c:a1e0::3]:5252"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("invalid query: %v", err)
+			}
+			filter, err := ParseFilter(query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, group := range TargetGroups(testDevices, DefaultPort, filter) {
+				got = append(got, group.Targets...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTargetGroups_Labels(t *testing.T) {
+	groups := TargetGroups(testDevices[1:3], 9100, Filter{})
+
+	want := []TargetGroup{
+		{
+			Targets: []string{"100.64.0.2:9100"},
+			Labels: map[string]string{
+				"tailscale_machine": "router",
+				"hostname":          "router",
+				"os":                "linux",
+				"user":              "alice@example.com",
+				"tags":              "tag:infra,tag:router",
+				"tailnet":           "example.com",
+			},
+		},
+		{
+			Targets: []string{"[fd7a:115c:a1e0::3]:9100"},
+			Labels: map[string]string{
+				"tailscale_machine": "v6-only",
+				"hostname":          "v6-only",
+				"os":                "linux",
+				"user":              "",
+				"tags":              "tag:infra",
+			},
+		},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("expected %+v, got %+v", want, groups)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := func(ctx context.Context) ([]Device, error) {
+		return testDevices[:1], nil
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(logger, source, DefaultPort).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd/devices", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	want := `[{"targets":["100.64.0.1:5252"],"labels":{"hostname":"alice-laptop","os":"macOS",` +
+		`"tags":"","tailnet":"example.com","tailscale_machine":"laptop","user":"alice@example.com"}}]`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(logger, source, DefaultPort).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd/devices?online=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid filter, got %d", rec.Code)
+	}
+
+	failing := func(ctx context.Context) ([]Device, error) {
+		return nil, errors.New("boom")
+	}
+	rec = httptest.NewRecorder()
+	Handler(logger, failing, DefaultPort).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd/devices", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 when listing fails, got %d", rec.Code)
+	}
+}
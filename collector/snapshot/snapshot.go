@@ -0,0 +1,114 @@
+// Package snapshot deduplicates API reads within a single scrape.
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats counts cache hits and misses per resource across scrapes.
+type Stats struct {
+	mu     sync.Mutex
+	counts map[string]*counts
+}
+
+type counts struct {
+	hits   float64
+	misses float64
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		counts: make(map[string]*counts),
+	}
+}
+
+func (s *Stats) record(resource string, hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counts[resource]
+	if !ok {
+		c = &counts{}
+		s.counts[resource] = c
+	}
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// Collect sends the hit and miss counters of every resource read so far. Both
+// descriptors must take a single resource label.
+func (s *Stats) Collect(ch chan<- prometheus.Metric, hitsDesc, missesDesc *prometheus.Desc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for resource, c := range s.counts {
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, c.hits, resource)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, c.misses, resource)
+	}
+}
+
+type result struct {
+	value any
+	err   error
+}
+
+// Cache holds the API responses of a single scrape. Concurrent reads of the
+// same resource are collapsed with singleflight and later reads are served
+// from the stored result, errors included, so every resource is fetched at
+// most once per scrape.
+type Cache struct {
+	group   singleflight.Group
+	mu      sync.Mutex
+	results map[string]result
+	stats   *Stats
+}
+
+// New returns an empty cache for one scrape that records into stats.
+func New(stats *Stats) *Cache {
+	return &Cache{
+		results: make(map[string]result),
+		stats:   stats,
+	}
+}
+
+func (c *Cache) lookup(resource string) (result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.results[resource]
+	return r, ok
+}
+
+// Fetch returns the cached result for resource, calling fetch only if no
+// other caller has read the resource during this scrape.
+func Fetch[T any](c *Cache, resource string, fetch func() (T, error)) (T, error) {
+	r, hit := c.lookup(resource)
+	if !hit {
+		fetched := false
+		value, err, _ := c.group.Do(resource, func() (any, error) {
+			// A previous flight may have finished between the lookup and Do.
+			if r, ok := c.lookup(resource); ok {
+				return r.value, r.err
+			}
+
+			fetched = true
+			value, err := fetch()
+			c.mu.Lock()
+			c.results[resource] = result{value: value, err: err}
+			c.mu.Unlock()
+			return value, err
+		})
+		r = result{value: value, err: err}
+		hit = !fetched
+	}
+	c.stats.record(resource, hit)
+
+	value, _ := r.value.(T)
+	return value, r.err
+}
@@ -0,0 +1,98 @@
+package snapshot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	testHitsDesc   = prometheus.NewDesc("test_cache_hits_total", "Hits.", []string{"resource"}, nil)
+	testMissesDesc = prometheus.NewDesc("test_cache_misses_total", "Misses.", []string{"resource"}, nil)
+)
+
+type statsCollector struct {
+	stats *Stats
+}
+
+func (c statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- testHitsDesc
+	ch <- testMissesDesc
+}
+
+func (c statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.stats.Collect(ch, testHitsDesc, testMissesDesc)
+}
+
+func TestFetch(t *testing.T) {
+	stats := NewStats()
+	cache := New(stats)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := Fetch(cache, "devices", func() ([]string, error) {
+				calls.Add(1)
+				<-release
+				return []string{"dev-1"}, nil
+			})
+			if err != nil || len(got) != 1 || got[0] != "dev-1" {
+				t.Errorf("unexpected result %v, %v", got, err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if _, err := Fetch(cache, "devices", func() ([]string, error) {
+		calls.Add(1)
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single fetch, got %d", n)
+	}
+
+	// Errors are cached for the rest of the scrape as well.
+	boom := errors.New("boom")
+	for range 2 {
+		if _, err := Fetch(cache, "users", func() ([]string, error) {
+			return nil, boom
+		}); !errors.Is(err, boom) {
+			t.Errorf("expected cached error, got %v", err)
+		}
+	}
+
+	// A new scrape fetches again and keeps counting into the same stats.
+	if _, err := Fetch(New(stats), "devices", func() ([]string, error) {
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP test_cache_hits_total Hits.
+# TYPE test_cache_hits_total counter
+test_cache_hits_total{resource="devices"} 4
+test_cache_hits_total{resource="users"} 1
+# HELP test_cache_misses_total Misses.
+# TYPE test_cache_misses_total counter
+test_cache_misses_total{resource="devices"} 2
+test_cache_misses_total{resource="users"} 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(statsCollector{stats: stats})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/fileutil"
+)
+
+const auditSubsystem = "audit"
+
+const (
+	// auditLogWindow bounds each configuration log request so that large gaps,
+	// e.g. after a restart with a persisted cursor, are paged through
+	// incrementally.
+	auditLogWindow = 6 * time.Hour
+	// auditLogRetention is how far back configuration logs are requested.
+	auditLogRetention = 30 * 24 * time.Hour
+	// auditLogDelay leaves time for recent events to become queryable before
+	// their window is read, as windows are not read again.
+	auditLogDelay = time.Minute
+)
+
+var (
+	auditEventsDesc = newDesc(
+		auditSubsystem,
+		"events_total",
+		"Number of configuration audit log events.",
+		[]string{"action", "actor_type", "target_type"},
+	)
+	auditLastEventDesc = newDesc(
+		auditSubsystem,
+		"last_event_timestamp",
+		"Unix timestamp of the most recent configuration audit log event.",
+		nil,
+	)
+	auditCursorDesc = newDesc(
+		auditSubsystem,
+		"cursor_timestamp",
+		"Unix timestamp up to which configuration audit logs have been read.",
+		nil,
+	)
+)
+
+// ConfigurationLog is a configuration audit log entry as returned by
+// /api/v2/tailnet/{tailnet}/logging/configuration.
+type ConfigurationLog struct {
+	EventGroupID string                 `json:"eventGroupID"`
+	Origin       string                 `json:"origin"`
+	Actor        ConfigurationLogActor  `json:"actor"`
+	Target       ConfigurationLogTarget `json:"target"`
+	Action       string                 `json:"action"`
+	EventTime    time.Time              `json:"eventTime"`
+}
+
+// ConfigurationLogActor is the user or client that made a configuration change.
+type ConfigurationLogActor struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	LoginName   string `json:"loginName"`
+	DisplayName string `json:"displayName"`
+}
+
+// ConfigurationLogTarget is the resource affected by a configuration change.
+type ConfigurationLogTarget struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Property string `json:"property"`
+}
+
+type auditEventKey struct {
+	action     string
+	actorType  string
+	targetType string
+}
+
+// auditState is the part of the audit collector state persisted across
+// restarts.
+type auditState struct {
+	Cursor time.Time `json:"cursor"`
+}
+
+type TailscaleAuditCollector struct {
+	log       *slog.Logger
+	stateFile string
+	now       func() time.Time
+
+	mtx       sync.Mutex
+	cursor    time.Time
+	lastEvent time.Time
+	events    map[auditEventKey]float64
+}
+
+func init() {
+	registerCollector(auditSubsystem, NewTailscaleAuditCollector)
+}
+
+// NewTailscaleAuditCollector creates the configuration audit log collector. It
+// is only enabled when configured, as it requires an additional OAuth scope.
+func NewTailscaleAuditCollector(config collectorConfig) (Collector, error) {
+	if !config.Audit {
+		return nil, nil
+	}
+
+	c := &TailscaleAuditCollector{
+		log:       config.logger,
+		stateFile: config.AuditStateFile,
+		now:       time.Now,
+		events:    make(map[auditEventKey]float64),
+	}
+
+	if c.stateFile != "" {
+		state, err := loadAuditState(c.stateFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit state: %w", err)
+		}
+		c.cursor = state.Cursor
+	}
+	if c.cursor.IsZero() {
+		// Only count events that happen after the exporter started.
+		c.cursor = c.now()
+	}
+
+	return c, nil
+}
+
+func (c *TailscaleAuditCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting audit metrics")
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	err := c.readLogs(ctx, client)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale configuration logs; ensure the OAuth client has logs:configuration:read",
+			"error",
+			err.Error(),
+		)
+	}
+
+	if c.stateFile != "" {
+		if stateErr := saveAuditState(c.stateFile, auditState{Cursor: c.cursor}); stateErr != nil {
+			c.log.ErrorContext(ctx, "Error saving audit state", "error", stateErr.Error())
+		}
+	}
+
+	for key, count := range c.events {
+		ch <- prometheus.MustNewConstMetric(
+			auditEventsDesc, prometheus.CounterValue, count,
+			key.action, key.actorType, key.targetType,
+		)
+	}
+	if !c.lastEvent.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			auditLastEventDesc, prometheus.GaugeValue, float64(c.lastEvent.Unix()),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		auditCursorDesc, prometheus.GaugeValue, float64(c.cursor.Unix()),
+	)
+
+	return err
+}
+
+// readLogs pages through the configuration logs since the cursor in windows of
+// auditLogWindow, up to auditLogDelay ago.
+func (c *TailscaleAuditCollector) readLogs(ctx context.Context, client TailscaleClient) error {
+	return readLogWindows(&c.cursor, c.now().Add(-auditLogDelay), auditLogWindow, auditLogRetention,
+		func(start, end time.Time) error {
+			logs, err := client.Logging().ConfigurationLogs(ctx, start, end)
+			if err != nil {
+				return err
+			}
+
+			for _, log := range logs {
+				// The start of the window is inclusive, skip events already counted.
+				if !log.EventTime.After(start) || log.EventTime.After(end) {
+					continue
+				}
+				c.events[auditEventKey{
+					action:     log.Action,
+					actorType:  log.Actor.Type,
+					targetType: log.Target.Type,
+				}]++
+				if log.EventTime.After(c.lastEvent) {
+					c.lastEvent = log.EventTime
+				}
+			}
+			return nil
+		},
+	)
+}
+
+func loadAuditState(path string) (auditState, error) {
+	var state auditState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+func saveAuditState(path string, state auditState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, data)
+}
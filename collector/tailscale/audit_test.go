@@ -0,0 +1,134 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTailscaleAuditCollector_Update(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "audit.json")
+	start := time.Unix(1_700_000_000, 0)
+	now := start
+
+	coll, err := NewTailscaleAuditCollector(collectorConfig{
+		logger: slog.Default(),
+		Config: Config{Audit: true, AuditStateFile: stateFile},
+	})
+	if err != nil {
+		t.Fatalf("failed to create audit collector: %v", err)
+	}
+	collector := coll.(*TailscaleAuditCollector)
+	collector.cursor = start
+	collector.now = func() time.Time { return now }
+
+	logging := &MockLoggingClient{}
+	client := &MockTailscaleClient{loggingClient: logging}
+
+	logging.configLogs = []ConfigurationLog{
+		{
+			Action:    "UPDATE",
+			Actor:     ConfigurationLogActor{Type: "USER"},
+			Target:    ConfigurationLogTarget{Type: "POLICY"},
+			EventTime: start.Add(-time.Minute),
+		},
+		{
+			Action:    "DELETE",
+			Actor:     ConfigurationLogActor{Type: "USER"},
+			Target:    ConfigurationLogTarget{Type: "NODE"},
+			EventTime: start.Add(time.Minute),
+		},
+		{
+			Action:    "DELETE",
+			Actor:     ConfigurationLogActor{Type: "USER"},
+			Target:    ConfigurationLogTarget{Type: "NODE"},
+			EventTime: start.Add(2 * time.Minute),
+		},
+	}
+
+	// Events are counted once, even when the same window is read again.
+	// Events that become queryable shortly after they happened are still
+	// counted, as the most recent minute is only read by the next scrape.
+	now = start.Add(10 * time.Minute)
+	collectAudit(t, collector, client)
+	logging.configLogs = append(logging.configLogs, ConfigurationLog{
+		Action:    "CREATE",
+		Actor:     ConfigurationLogActor{Type: "USER"},
+		Target:    ConfigurationLogTarget{Type: "NODE"},
+		EventTime: start.Add(9*time.Minute + 30*time.Second),
+	})
+	now = start.Add(15 * time.Hour)
+	metrics := collectAudit(t, collector, client)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP tailscale_audit_cursor_timestamp Unix timestamp up to which configuration audit logs have been read.
+# TYPE tailscale_audit_cursor_timestamp gauge
+tailscale_audit_cursor_timestamp 1.70005394e+09
+# HELP tailscale_audit_events_total Number of configuration audit log events.
+# TYPE tailscale_audit_events_total counter
+tailscale_audit_events_total{action="CREATE",actor_type="USER",target_type="NODE"} 1
+tailscale_audit_events_total{action="DELETE",actor_type="USER",target_type="NODE"} 2
+# HELP tailscale_audit_last_event_timestamp Unix timestamp of the most recent configuration audit log event.
+# TYPE tailscale_audit_last_event_timestamp gauge
+tailscale_audit_last_event_timestamp 1.70000057e+09
+`)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+
+	state, err := loadAuditState(stateFile)
+	if err != nil {
+		t.Fatalf("failed to load audit state: %v", err)
+	}
+	cursor := now.Add(-auditLogDelay)
+	if !state.Cursor.Equal(cursor) {
+		t.Errorf("persisted cursor = %v, want %v", state.Cursor, cursor)
+	}
+
+	restarted, err := NewTailscaleAuditCollector(collectorConfig{
+		logger: slog.Default(),
+		Config: Config{Audit: true, AuditStateFile: stateFile},
+	})
+	if err != nil {
+		t.Fatalf("failed to create audit collector: %v", err)
+	}
+	if restored := restarted.(*TailscaleAuditCollector).cursor; !restored.Equal(cursor) {
+		t.Errorf("restored cursor = %v, want %v", restored, cursor)
+	}
+}
+
+func collectAudit(
+	t *testing.T,
+	collector *TailscaleAuditCollector,
+	client TailscaleClient,
+) []prometheus.Metric {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 16)
+	if err := collector.Update(context.Background(), client, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(ch)
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func TestNewTailscaleAuditCollector_Disabled(t *testing.T) {
+	coll, err := NewTailscaleAuditCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coll != nil {
+		t.Errorf("expected audit collector to be disabled")
+	}
+}
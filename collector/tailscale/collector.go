@@ -10,6 +10,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"tailscale.com/client/tailscale/v2"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
 )
 
 const (
@@ -52,8 +55,55 @@ func boolAsFloat(b bool) float64 {
 	return 0
 }
 
+// Config holds optional settings shared by the Tailscale collectors.
+type Config struct {
+	// DERPLatencyMode controls how device DERP latency is exported. One of
+	// DERPLatencyModeDevice (default), DERPLatencyModeAggregated or
+	// DERPLatencyModeBoth.
+	DERPLatencyMode string
+	// Contacts enables the contacts collector, which requires the
+	// account_settings:read scope.
+	Contacts bool
+	// ContactsHashKey is the secret key of the HMAC-SHA256 hash that contact
+	// emails are exported as. It is required with Contacts.
+	ContactsHashKey string
+	// Webhooks enables the webhooks collector, which requires the
+	// webhooks:read scope.
+	Webhooks bool
+	// LogStreaming enables the log streaming collector, which requires the
+	// log_streaming:read scope.
+	LogStreaming bool
+	// Audit enables the configuration audit log collector, which requires the
+	// logs:configuration:read scope.
+	Audit bool
+	// KeysLongLivedThreshold is the lifetime above which reusable auth keys
+	// are counted as long-lived, 30 days by default.
+	KeysLongLivedThreshold time.Duration
+	// AuditStateFile, when set, persists the configuration audit log cursor
+	// across restarts.
+	AuditStateFile string
+	// FlowLogsAggregation enables the network flow logs collector and selects
+	// whether traffic is aggregated by device, tag or user.
+	FlowLogsAggregation string
+	// DNSProbeQuery enables the DNS probe collector and is the name queried on
+	// global nameservers. Split DNS nameservers are queried for their domain.
+	DNSProbeQuery string
+	// DNSProbeQueryType is the record type of probe queries, A by default.
+	DNSProbeQueryType string
+	// DNSProbeTimeout bounds each probe query.
+	DNSProbeTimeout time.Duration
+	// LifecycleStateFile, when set, persists the devices seen by the lifecycle
+	// collector so that changes during a restart are counted.
+	LifecycleStateFile string
+	// SDStore, when set, receives the devices listed by the devices collector
+	// for file based service discovery.
+	SDStore *sd.Store
+}
+
 type collectorConfig struct {
-	logger *slog.Logger
+	logger  *slog.Logger
+	tailnet string
+	Config
 }
 
 func newDesc(
@@ -84,19 +134,29 @@ type Collector interface {
 
 // TailscaleCollector collects comprehensive Tailscale metrics.
 type TailscaleCollector struct {
-	client TailscaleClient
+	client     TailscaleClient
+	tailnet    string
+	cacheStats *snapshot.Stats
 
 	Collectors map[string]Collector
 	logger     *slog.Logger
 }
 
 type TailscaleClient interface {
+	Contacts() ContactsAPI
 	Keys() KeysAPI
 	DNS() DNSAPI
 	Devices() DevicesAPI
+	Logging() LoggingAPI
 	Services() ServicesAPI
 	Users() UsersAPI
 	TailnetSettings() TailnetSettingsAPI
+	Webhooks() WebhooksAPI
+}
+
+// ContactsAPI is the subset of *tailscale.ContactsResource you actually use
+type ContactsAPI interface {
+	Get(ctx context.Context) (*tailscale.Contacts, error)
 }
 
 // KeysAPI is the subset of *tailscale.KeysResource you actually use
@@ -108,6 +168,7 @@ type KeysAPI interface {
 type DNSAPI interface {
 	Nameservers(ctx context.Context) ([]string, error)
 	Preferences(ctx context.Context) (*tailscale.DNSPreferences, error)
+	Configuration(ctx context.Context) (*tailscale.DNSConfiguration, error)
 }
 
 // DevicesAPI is the subset of *tailscale.DevicesResource you actually use
@@ -115,6 +176,22 @@ type DevicesAPI interface {
 	List(ctx context.Context, opts ...tailscale.ListDevicesOptions) ([]tailscale.Device, error)
 }
 
+// LoggingAPI is the subset of *tailscale.LoggingResource you actually use,
+// extended with the log streaming status.
+type LoggingAPI interface {
+	LogstreamConfiguration(
+		ctx context.Context,
+		logType tailscale.LogType,
+	) (*tailscale.LogstreamConfiguration, error)
+	LogstreamStatus(ctx context.Context, logType tailscale.LogType) (*LogstreamStatus, error)
+	ConfigurationLogs(ctx context.Context, start, end time.Time) ([]ConfigurationLog, error)
+	GetNetworkFlowLogs(
+		ctx context.Context,
+		params tailscale.NetworkFlowLogsRequest,
+		handler tailscale.NetworkFlowLogHandler,
+	) error
+}
+
 // ServicesAPI is the subset of *tailscale.ServicesResource you actually use
 type ServicesAPI interface {
 	List(ctx context.Context) ([]tailscale.Service, error)
@@ -134,6 +211,11 @@ type TailnetSettingsAPI interface {
 	Get(ctx context.Context) (*tailscale.TailnetSettings, error)
 }
 
+// WebhooksAPI is the subset of *tailscale.WebhooksResource you actually use
+type WebhooksAPI interface {
+	List(ctx context.Context) ([]tailscale.Webhook, error)
+}
+
 // TailscaleClientWrapper wraps the real tailscale.Client to implement our TailscaleClient interface
 type TailscaleClientWrapper struct {
 	client *tailscale.Client
@@ -145,6 +227,10 @@ func NewTailscaleClientWrapper(client *tailscale.Client) *TailscaleClientWrapper
 	}
 }
 
+func (w *TailscaleClientWrapper) Contacts() ContactsAPI {
+	return w.client.Contacts()
+}
+
 func (w *TailscaleClientWrapper) Keys() KeysAPI {
 	return w.client.Keys()
 }
@@ -157,6 +243,10 @@ func (w *TailscaleClientWrapper) Devices() DevicesAPI {
 	return w.client.Devices()
 }
 
+func (w *TailscaleClientWrapper) Logging() LoggingAPI {
+	return loggingResource{w.client.Logging()}
+}
+
 func (w *TailscaleClientWrapper) Services() ServicesAPI {
 	return w.client.Services()
 }
@@ -169,14 +259,21 @@ func (w *TailscaleClientWrapper) TailnetSettings() TailnetSettingsAPI {
 	return w.client.TailnetSettings()
 }
 
+func (w *TailscaleClientWrapper) Webhooks() WebhooksAPI {
+	return w.client.Webhooks()
+}
+
 // NewTailscaleCollector creates the Tailscale collector.
 func NewTailscaleCollector(
 	logger *slog.Logger,
 	httpClient *http.Client,
 	tailnet string,
+	config Config,
 ) (*TailscaleCollector, error) {
 	t := &TailscaleCollector{
-		logger: logger,
+		logger:     logger,
+		tailnet:    tailnet,
+		cacheStats: snapshot.NewStats(),
 	}
 
 	collectors := make(map[string]Collector)
@@ -187,11 +284,17 @@ func NewTailscaleCollector(
 			collectors[key] = collector
 		} else {
 			coll, err := factories[key](collectorConfig{
-				logger: logger.With("collector", key),
+				logger:  logger.With("collector", key),
+				tailnet: tailnet,
+				Config:  config,
 			})
 			if err != nil {
 				return nil, err
 			}
+			if coll == nil {
+				// The collector is disabled by its configuration.
+				continue
+			}
 			collectors[key] = coll
 			initiatedCollectors[key] = coll
 		}
@@ -213,6 +316,8 @@ func (t *TailscaleCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- upDesc
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- scrapeCacheHitsDesc
+	ch <- scrapeCacheMissesDesc
 }
 
 func (t *TailscaleCollector) Collect(ch chan<- prometheus.Metric) {
@@ -220,13 +325,16 @@ func (t *TailscaleCollector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
 	wg.Add(len(t.Collectors))
 
+	// Every collector of this scrape reads from the same snapshot.
+	client := newSnapshotClient(t.client, t.cacheStats)
 	for name, c := range t.Collectors {
 		go func(name string, c Collector) {
-			execute(ctx, name, c, t.client, ch, t.logger)
+			execute(ctx, name, c, client, ch, t.logger)
 			wg.Done()
 		}(name, c)
 	}
 	wg.Wait()
+	t.cacheStats.Collect(ch, scrapeCacheHitsDesc, scrapeCacheMissesDesc)
 	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
 }
 
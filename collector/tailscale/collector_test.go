@@ -2,6 +2,8 @@ package tailscale
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"tailscale.com/client/tailscale/v2"
@@ -22,6 +24,19 @@ func (c *TestMetricCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+// MockContactsClient implements the ContactsAPI interface for testing
+type MockContactsClient struct {
+	contacts    *tailscale.Contacts
+	contactsErr error
+}
+
+func (m *MockContactsClient) Get(ctx context.Context) (*tailscale.Contacts, error) {
+	if m.contactsErr != nil {
+		return nil, m.contactsErr
+	}
+	return m.contacts, nil
+}
+
 // MockKeysClient implements the KeysAPI interface for testing
 type MockKeysClient struct {
 	keys    []tailscale.Key
@@ -41,6 +56,8 @@ type MockDNSClient struct {
 	nameserversErr error
 	preferences    *tailscale.DNSPreferences
 	preferencesErr error
+	configuration  *tailscale.DNSConfiguration
+	configErr      error
 }
 
 func (m *MockDNSClient) Nameservers(ctx context.Context) ([]string, error) {
@@ -57,12 +74,92 @@ func (m *MockDNSClient) Preferences(ctx context.Context) (*tailscale.DNSPreferen
 	return m.preferences, nil
 }
 
+func (m *MockDNSClient) Configuration(ctx context.Context) (*tailscale.DNSConfiguration, error) {
+	if m.configErr != nil {
+		return nil, m.configErr
+	}
+	if m.configuration == nil {
+		return &tailscale.DNSConfiguration{}, nil
+	}
+	return m.configuration, nil
+}
+
 // MockDevicesClient implements the DevicesAPI interface for testing
 type MockDevicesClient struct {
 	devices    []tailscale.Device
 	devicesErr error
 }
 
+// MockLoggingClient implements the LoggingAPI interface for testing
+type MockLoggingClient struct {
+	configurations map[tailscale.LogType]*tailscale.LogstreamConfiguration
+	statuses       map[tailscale.LogType]*LogstreamStatus
+	configLogs     []ConfigurationLog
+	flowLogs       []tailscale.NetworkFlowLog
+	loggingErr     error
+	// flowLogsErr fails flow log requests after the logs were streamed.
+	flowLogsErr error
+}
+
+func (m *MockLoggingClient) LogstreamConfiguration(
+	ctx context.Context,
+	logType tailscale.LogType,
+) (*tailscale.LogstreamConfiguration, error) {
+	if m.loggingErr != nil {
+		return nil, m.loggingErr
+	}
+	config, ok := m.configurations[logType]
+	if !ok {
+		return nil, tailscale.APIError{Status: http.StatusNotFound}
+	}
+	return config, nil
+}
+
+func (m *MockLoggingClient) LogstreamStatus(
+	ctx context.Context,
+	logType tailscale.LogType,
+) (*LogstreamStatus, error) {
+	if m.loggingErr != nil {
+		return nil, m.loggingErr
+	}
+	return m.statuses[logType], nil
+}
+
+func (m *MockLoggingClient) ConfigurationLogs(
+	ctx context.Context,
+	start, end time.Time,
+) ([]ConfigurationLog, error) {
+	if m.loggingErr != nil {
+		return nil, m.loggingErr
+	}
+	var logs []ConfigurationLog
+	for _, log := range m.configLogs {
+		if !log.EventTime.Before(start) && !log.EventTime.After(end) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (m *MockLoggingClient) GetNetworkFlowLogs(
+	ctx context.Context,
+	params tailscale.NetworkFlowLogsRequest,
+	handler tailscale.NetworkFlowLogHandler,
+) error {
+	if m.loggingErr != nil {
+		return m.loggingErr
+	}
+	for _, log := range m.flowLogs {
+		if log.Logged.Before(params.Start) || log.Logged.After(params.End) {
+			continue
+		}
+		if err := handler(log); err != nil {
+			return err
+		}
+	}
+	return m.flowLogsErr
+}
+
 // MockServicesClient implements the ServicesAPI interface for testing
 type MockServicesClient struct {
 	services    []tailscale.Service
@@ -116,14 +213,34 @@ func (m *MockTailnetSettingsClient) Get(ctx context.Context) (*tailscale.Tailnet
 	return m.settings, nil
 }
 
+// MockWebhooksClient implements the WebhooksAPI interface for testing
+type MockWebhooksClient struct {
+	webhooks    []tailscale.Webhook
+	webhooksErr error
+}
+
+func (m *MockWebhooksClient) List(ctx context.Context) ([]tailscale.Webhook, error) {
+	if m.webhooksErr != nil {
+		return nil, m.webhooksErr
+	}
+	return m.webhooks, nil
+}
+
 // MockTailscaleClient implements the TailscaleClient interface for testing
 type MockTailscaleClient struct {
+	contactsClient        *MockContactsClient
 	dnsClient             *MockDNSClient
 	keysClient            *MockKeysClient
 	devicesClient         *MockDevicesClient
+	loggingClient         *MockLoggingClient
 	servicesClient        *MockServicesClient
 	usersClient           *MockUsersClient
 	tailnetSettingsClient *MockTailnetSettingsClient
+	webhooksClient        *MockWebhooksClient
+}
+
+func (m *MockTailscaleClient) Contacts() ContactsAPI {
+	return m.contactsClient
 }
 
 func (m *MockTailscaleClient) DNS() DNSAPI {
@@ -138,6 +255,10 @@ func (m *MockTailscaleClient) Devices() DevicesAPI {
 	return m.devicesClient
 }
 
+func (m *MockTailscaleClient) Logging() LoggingAPI {
+	return m.loggingClient
+}
+
 func (m *MockTailscaleClient) Services() ServicesAPI {
 	return m.servicesClient
 }
@@ -149,3 +270,7 @@ func (m *MockTailscaleClient) Users() UsersAPI {
 func (m *MockTailscaleClient) TailnetSettings() TailnetSettingsAPI {
 	return m.tailnetSettingsClient
 }
+
+func (m *MockTailscaleClient) Webhooks() WebhooksAPI {
+	return m.webhooksClient
+}
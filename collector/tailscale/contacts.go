@@ -0,0 +1,117 @@
+package tailscale
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
+)
+
+const contactsSubsystem = "contacts"
+
+var (
+	contactsInfoDesc = newDesc(
+		contactsSubsystem,
+		"info",
+		"Tailnet contact information. The email is exposed as a keyed HMAC-SHA256 hash.",
+		[]string{"contact_type", "email_hash"},
+	)
+	contactsEmailSetDesc = newDesc(
+		contactsSubsystem,
+		"email_set",
+		"Whether an email is set for the tailnet contact.",
+		[]string{"contact_type"},
+	)
+	contactsVerifiedDesc = newDesc(
+		contactsSubsystem,
+		"verified",
+		"Whether the email of the tailnet contact is verified.",
+		[]string{"contact_type"},
+	)
+)
+
+type TailscaleContactsCollector struct {
+	log     *slog.Logger
+	hashKey []byte
+}
+
+func init() {
+	registerCollector(contactsSubsystem, NewTailscaleContactsCollector)
+}
+
+// NewTailscaleContactsCollector creates the contacts collector. It is only
+// enabled when configured, as it requires an additional OAuth scope.
+func NewTailscaleContactsCollector(config collectorConfig) (Collector, error) {
+	if !config.Contacts {
+		return nil, nil
+	}
+	if config.ContactsHashKey == "" {
+		return nil, errors.New("contacts hash key must be set")
+	}
+
+	return &TailscaleContactsCollector{
+		log:     config.logger,
+		hashKey: []byte(config.ContactsHashKey),
+	}, nil
+}
+
+func (c TailscaleContactsCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting contacts metrics")
+
+	contacts, err := client.Contacts().Get(ctx)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale contacts; ensure the OAuth client has account_settings:read",
+			"error",
+			err.Error(),
+		)
+		return err
+	}
+
+	for contactType, contact := range map[tailscale.ContactType]tailscale.Contact{
+		tailscale.ContactAccount:  contacts.Account,
+		tailscale.ContactSupport:  contacts.Support,
+		tailscale.ContactSecurity: contacts.Security,
+	} {
+		emailSet := contact.Email != ""
+
+		ch <- prometheus.MustNewConstMetric(
+			contactsInfoDesc, prometheus.GaugeValue, 1,
+			string(contactType), c.hashEmail(contact.Email),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			contactsEmailSetDesc, prometheus.GaugeValue, boolAsFloat(emailSet),
+			string(contactType),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			contactsVerifiedDesc, prometheus.GaugeValue, boolAsFloat(emailSet && !contact.NeedsVerification),
+			string(contactType),
+		)
+	}
+
+	return nil
+}
+
+// hashEmail returns the hex encoded HMAC-SHA256 of a normalized email address,
+// or an empty string when no email is set. Unlike a plain hash, it cannot be
+// reversed by hashing candidate addresses without the key.
+func (c TailscaleContactsCollector) hashEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(email))
+	return hex.EncodeToString(mac.Sum(nil))
+}
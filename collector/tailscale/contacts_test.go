@@ -0,0 +1,81 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleContactsCollector_Update(t *testing.T) {
+	collector := &TailscaleContactsCollector{log: slog.Default(), hashKey: []byte("test-hash-key")}
+	ch := make(chan prometheus.Metric, 16)
+
+	err := collector.Update(context.Background(), &MockTailscaleClient{
+		contactsClient: &MockContactsClient{
+			contacts: &tailscale.Contacts{
+				Account: tailscale.Contact{Email: "Admin@example.com"},
+				Security: tailscale.Contact{
+					Email:             "security@example.com",
+					NeedsVerification: true,
+				},
+			},
+		},
+	}, ch)
+	close(ch)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP tailscale_contacts_email_set Whether an email is set for the tailnet contact.
+# TYPE tailscale_contacts_email_set gauge
+tailscale_contacts_email_set{contact_type="account"} 1
+tailscale_contacts_email_set{contact_type="security"} 1
+tailscale_contacts_email_set{contact_type="support"} 0
+# HELP tailscale_contacts_info Tailnet contact information. The email is exposed as a keyed HMAC-SHA256 hash.
+# TYPE tailscale_contacts_info gauge
+tailscale_contacts_info{contact_type="account",email_hash="7c6bebbd40b36fe5af37b79337960c7c8aeee3f6668b7c426f817d8486f56398"} 1
+tailscale_contacts_info{contact_type="security",email_hash="333be50a27f94470d89cdde8716f49d30cd9cd8fae9ed55693b48a10074a3137"} 1
+tailscale_contacts_info{contact_type="support",email_hash=""} 1
+# HELP tailscale_contacts_verified Whether the email of the tailnet contact is verified.
+# TYPE tailscale_contacts_verified gauge
+tailscale_contacts_verified{contact_type="account"} 1
+tailscale_contacts_verified{contact_type="security"} 0
+tailscale_contacts_verified{contact_type="support"} 0
+`)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
+
+func TestNewTailscaleContactsCollector_Disabled(t *testing.T) {
+	coll, err := NewTailscaleContactsCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coll != nil {
+		t.Errorf("expected contacts collector to be disabled")
+	}
+}
+
+func TestNewTailscaleContactsCollector_MissingHashKey(t *testing.T) {
+	_, err := NewTailscaleContactsCollector(collectorConfig{
+		logger: slog.Default(),
+		Config: Config{Contacts: true},
+	})
+	if err == nil {
+		t.Errorf("expected an error without a hash key")
+	}
+}
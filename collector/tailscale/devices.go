@@ -2,6 +2,7 @@ package tailscale
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -9,10 +10,26 @@ import (
 	"tailscale.com/client/tailscale/v2"
 
 	"github.com/adinhodovic/tailscale-exporter/collector/iputil"
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
 )
 
 const devicesSubsystem = "devices"
 
+// DERP latency export modes.
+const (
+	// DERPLatencyModeDevice exports one latency gauge per device and DERP region.
+	DERPLatencyModeDevice = "device"
+	// DERPLatencyModeAggregated exports a latency histogram per DERP region and
+	// only the best region per device.
+	DERPLatencyModeAggregated = "aggregated"
+	// DERPLatencyModeBoth exports both the per-device gauges and the aggregates.
+	DERPLatencyModeBoth = "both"
+)
+
+// derpLatencyBuckets are the histogram buckets, in milliseconds, used for the
+// aggregated DERP latency histogram.
+var derpLatencyBuckets = []float64{5, 10, 25, 50, 75, 100, 150, 200, 300, 500, 1000}
+
 var (
 	deviceesInfoDesc = newDesc(
 		devicesSubsystem,
@@ -68,6 +85,21 @@ var (
 			"name", "hostname", "os", "user", "derp_region",
 		},
 	)
+	devicesDERPLatencyDesc = newDesc(
+		devicesSubsystem,
+		"derp_latency_ms",
+		"Distribution of device latency to each DERP region in milliseconds",
+		[]string{"derp_region"},
+	)
+	devicesBestLatencyDesc = newDesc(
+		devicesSubsystem,
+		"best_latency_ms",
+		"Device latency to its lowest-latency DERP region in milliseconds",
+		[]string{
+			"id",
+			"name", "hostname", "os", "user", "derp_region",
+		},
+	)
 	devicesRoutesAdvertisedDesc = newDesc(
 		devicesSubsystem,
 		"routes_advertised",
@@ -122,10 +154,54 @@ var (
 		"Whether device blocks incoming connections",
 		[]string{"id", "name", "hostname", "os", "user"},
 	)
+	devicesEndpointInfoDesc = newDesc(
+		devicesSubsystem,
+		"endpoint_info",
+		"Endpoint reported by the device client",
+		[]string{"id", "name", "hostname", "os", "user", "endpoint"},
+	)
+	devicesMappingVariesByDestIPDesc = newDesc(
+		devicesSubsystem,
+		"mapping_varies_by_dest_ip",
+		"Whether the device NAT mapping varies by destination IP (hard NAT)",
+		[]string{"id", "name", "hostname", "os", "user"},
+	)
+	devicesClientSupportsDesc = newDesc(
+		devicesSubsystem,
+		"client_supports",
+		"Whether the device client supports a connectivity feature",
+		[]string{"id", "name", "hostname", "os", "user", "feature"},
+	)
+	devicesDERPHomeRegionDesc = newDesc(
+		devicesSubsystem,
+		"derp_home_region_info",
+		"Preferred (home) DERP region of the device",
+		[]string{"id", "name", "hostname", "os", "user", "derp_region"},
+	)
+	devicesDERPHomeRegionCountDesc = newDesc(
+		devicesSubsystem,
+		"derp_home_region_count",
+		"Number of devices per preferred (home) DERP region",
+		[]string{"derp_region"},
+	)
+	devicesNATTypeCountDesc = newDesc(
+		devicesSubsystem,
+		"nat_type_count",
+		"Number of devices per NAT type (hard when the mapping varies by destination IP)",
+		[]string{"nat_type"},
+	)
+)
+
+const (
+	natTypeEasy = "easy"
+	natTypeHard = "hard"
 )
 
 type TailscaleDevicesCollector struct {
-	log *slog.Logger
+	log             *slog.Logger
+	derpLatencyMode string
+	tailnet         string
+	sdStore         *sd.Store
 }
 
 func init() {
@@ -133,8 +209,20 @@ func init() {
 }
 
 func NewTailscaleDevicesCollector(config collectorConfig) (Collector, error) {
+	mode := config.DERPLatencyMode
+	switch mode {
+	case "":
+		mode = DERPLatencyModeDevice
+	case DERPLatencyModeDevice, DERPLatencyModeAggregated, DERPLatencyModeBoth:
+	default:
+		return nil, fmt.Errorf("invalid DERP latency mode %q", mode)
+	}
+
 	return &TailscaleDevicesCollector{
-		log: config.logger,
+		log:             config.logger,
+		derpLatencyMode: mode,
+		tailnet:         config.tailnet,
+		sdStore:         config.SDStore,
 	}, nil
 }
 
@@ -145,10 +233,7 @@ func (c TailscaleDevicesCollector) Update(
 ) error {
 	c.log.DebugContext(ctx, "Collecting devices metrics")
 
-	devices, err := client.Devices().List(
-		ctx,
-		tailscale.WithFields(tailscale.IncludeFieldsAll),
-	)
+	devices, err := client.Devices().List(ctx, tailscale.WithFields(tailscale.IncludeFieldsAll))
 	if err != nil {
 		c.log.ErrorContext(
 			ctx,
@@ -159,6 +244,18 @@ func (c TailscaleDevicesCollector) Update(
 		return err
 	}
 
+	if c.sdStore != nil {
+		c.sdStore.Set(namespace, sdDevices(devices, c.tailnet))
+	}
+
+	perDeviceLatency := c.derpLatencyMode != DERPLatencyModeAggregated
+	aggregatedLatency := c.derpLatencyMode == DERPLatencyModeAggregated ||
+		c.derpLatencyMode == DERPLatencyModeBoth
+	regionLatencies := make(map[string][]float64)
+
+	homeRegionCounts := make(map[string]int)
+	natTypeCounts := map[string]int{natTypeEasy: 0, natTypeHard: 0}
+
 	// Device metrics
 	for _, device := range devices {
 		tailscaleIP, tailscaleIPv6 := iputil.SplitIPs(device.Addresses)
@@ -237,11 +334,115 @@ func (c TailscaleDevicesCollector) Update(
 		// Latency metrics
 		if device.ClientConnectivity != nil &&
 			device.ClientConnectivity.DERPLatency != nil {
+			bestRegion := ""
+			bestLatency := 0.0
 			for destination, latency := range device.ClientConnectivity.DERPLatency {
-				ch <- prometheus.MustNewConstMetric(devicesLatencyDesc, prometheus.GaugeValue, latency.LatencyMilliseconds,
-					device.ID, device.Name, device.Hostname, device.OS, device.User, destination)
+				if perDeviceLatency {
+					ch <- prometheus.MustNewConstMetric(devicesLatencyDesc, prometheus.GaugeValue, latency.LatencyMilliseconds,
+						device.ID, device.Name, device.Hostname, device.OS, device.User, destination)
+				}
+				if aggregatedLatency {
+					regionLatencies[destination] = append(regionLatencies[destination], latency.LatencyMilliseconds)
+				}
+				if bestRegion == "" || latency.LatencyMilliseconds < bestLatency {
+					bestRegion = destination
+					bestLatency = latency.LatencyMilliseconds
+				}
+			}
+			if aggregatedLatency && bestRegion != "" {
+				ch <- prometheus.MustNewConstMetric(devicesBestLatencyDesc, prometheus.GaugeValue, bestLatency,
+					device.ID, device.Name, device.Hostname, device.OS, device.User, bestRegion)
+			}
+		}
+
+		// Connectivity metrics
+		if cc := device.ClientConnectivity; cc != nil {
+			for _, endpoint := range cc.Endpoints {
+				ch <- prometheus.MustNewConstMetric(devicesEndpointInfoDesc, prometheus.GaugeValue, 1,
+					device.ID, device.Name, device.Hostname, device.OS, device.User, endpoint)
+			}
+
+			ch <- prometheus.MustNewConstMetric(devicesMappingVariesByDestIPDesc, prometheus.GaugeValue,
+				boolAsFloat(cc.MappingVariesByDestIP),
+				device.ID, device.Name, device.Hostname, device.OS, device.User)
+			if cc.MappingVariesByDestIP {
+				natTypeCounts[natTypeHard]++
+			} else {
+				natTypeCounts[natTypeEasy]++
+			}
+
+			for feature, supported := range clientSupportsFeatures(cc.ClientSupports) {
+				ch <- prometheus.MustNewConstMetric(devicesClientSupportsDesc, prometheus.GaugeValue,
+					boolAsFloat(supported),
+					device.ID, device.Name, device.Hostname, device.OS, device.User, feature)
+			}
+
+			if region := homeDERPRegion(cc); region != "" {
+				ch <- prometheus.MustNewConstMetric(devicesDERPHomeRegionDesc, prometheus.GaugeValue, 1,
+					device.ID, device.Name, device.Hostname, device.OS, device.User, region)
+				homeRegionCounts[region]++
 			}
 		}
 	}
+
+	if aggregatedLatency {
+		for region, latencies := range regionLatencies {
+			count, sum, buckets := latencyHistogram(latencies)
+			ch <- prometheus.MustNewConstHistogram(devicesDERPLatencyDesc, count, sum, buckets, region)
+		}
+	}
+
+	for region, count := range homeRegionCounts {
+		ch <- prometheus.MustNewConstMetric(devicesDERPHomeRegionCountDesc, prometheus.GaugeValue,
+			float64(count), region)
+	}
+	for natType, count := range natTypeCounts {
+		ch <- prometheus.MustNewConstMetric(devicesNATTypeCountDesc, prometheus.GaugeValue,
+			float64(count), natType)
+	}
+
 	return nil
 }
+
+// latencyHistogram buckets the observed latencies into derpLatencyBuckets,
+// returning the count, sum and cumulative bucket counts.
+func latencyHistogram(latencies []float64) (uint64, float64, map[float64]uint64) {
+	sum := 0.0
+	buckets := make(map[float64]uint64, len(derpLatencyBuckets))
+	for _, upperBound := range derpLatencyBuckets {
+		buckets[upperBound] = 0
+	}
+	for _, latency := range latencies {
+		sum += latency
+		for _, upperBound := range derpLatencyBuckets {
+			if latency <= upperBound {
+				buckets[upperBound]++
+			}
+		}
+	}
+	return uint64(len(latencies)), sum, buckets
+}
+
+// clientSupportsFeatures maps the connectivity features reported by a client to
+// the values used for the feature label.
+func clientSupportsFeatures(s tailscale.ClientSupports) map[string]bool {
+	return map[string]bool{
+		"hairpinning": s.HairPinning,
+		"ipv6":        s.IPV6,
+		"pcp":         s.PCP,
+		"pmp":         s.PMP,
+		"udp":         s.UDP,
+		"upnp":        s.UPNP,
+	}
+}
+
+// homeDERPRegion returns the preferred DERP region of a client. The region
+// marked as preferred in the latency map wins, falling back to the DERP field.
+func homeDERPRegion(cc *tailscale.ClientConnectivity) string {
+	for region, latency := range cc.DERPLatency {
+		if latency.Preferred {
+			return region
+		}
+	}
+	return cc.DERP
+}
@@ -17,8 +17,10 @@ func TestTailscaleDevicesCollector_Update(t *testing.T) {
 
 	tests := []struct {
 		name            string
+		derpLatencyMode string
 		mockClient      *MockTailscaleClient
 		expectedMetrics string
+		metricNames     []string
 		expectError     bool
 	}{
 		{
@@ -55,10 +57,16 @@ func TestTailscaleDevicesCollector_Update(t *testing.T) {
 							MachineKey: "mkey:abcd1234",
 							NodeKey:    "nodekey:efgh5678",
 							ClientConnectivity: &tailscale.ClientConnectivity{
+								Endpoints:             []string{"203.0.113.10:41641"},
+								MappingVariesByDestIP: true,
 								DERPLatency: map[string]tailscale.DERPRegion{
-									"nyc": {LatencyMilliseconds: 50},
+									"nyc": {LatencyMilliseconds: 50, Preferred: true},
 									"lax": {LatencyMilliseconds: 100},
 								},
+								ClientSupports: tailscale.ClientSupports{
+									IPV6: true,
+									UDP:  true,
+								},
 							},
 							AdvertisedRoutes: []string{"192.168.1.0/24"},
 							EnabledRoutes:    []string{"192.168.1.0/24"},
@@ -107,20 +115,121 @@ tailscale_devices_routes_advertised{hostname="device-one",id="device-123",name="
 # HELP tailscale_devices_routes_enabled Number of routes enabled for device
 # TYPE tailscale_devices_routes_enabled gauge
 tailscale_devices_routes_enabled{hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+# HELP tailscale_devices_endpoint_info Endpoint reported by the device client
+# TYPE tailscale_devices_endpoint_info gauge
+tailscale_devices_endpoint_info{endpoint="203.0.113.10:41641",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+# HELP tailscale_devices_mapping_varies_by_dest_ip Whether the device NAT mapping varies by destination IP (hard NAT)
+# TYPE tailscale_devices_mapping_varies_by_dest_ip gauge
+tailscale_devices_mapping_varies_by_dest_ip{hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+# HELP tailscale_devices_client_supports Whether the device client supports a connectivity feature
+# TYPE tailscale_devices_client_supports gauge
+tailscale_devices_client_supports{feature="hairpinning",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 0
+tailscale_devices_client_supports{feature="ipv6",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+tailscale_devices_client_supports{feature="pcp",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 0
+tailscale_devices_client_supports{feature="pmp",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 0
+tailscale_devices_client_supports{feature="udp",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+tailscale_devices_client_supports{feature="upnp",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 0
+# HELP tailscale_devices_derp_home_region_info Preferred (home) DERP region of the device
+# TYPE tailscale_devices_derp_home_region_info gauge
+tailscale_devices_derp_home_region_info{derp_region="nyc",hostname="device-one",id="device-123",name="Device One",os="linux",user="user-456"} 1
+# HELP tailscale_devices_derp_home_region_count Number of devices per preferred (home) DERP region
+# TYPE tailscale_devices_derp_home_region_count gauge
+tailscale_devices_derp_home_region_count{derp_region="nyc"} 1
+# HELP tailscale_devices_nat_type_count Number of devices per NAT type (hard when the mapping varies by destination IP)
+# TYPE tailscale_devices_nat_type_count gauge
+tailscale_devices_nat_type_count{nat_type="easy"} 0
+tailscale_devices_nat_type_count{nat_type="hard"} 1
 `,
 			expectError: false,
 		},
+		{
+			name:            "aggregated DERP latency mode",
+			derpLatencyMode: DERPLatencyModeAggregated,
+			mockClient: &MockTailscaleClient{
+				devicesClient: &MockDevicesClient{
+					devices: []tailscale.Device{
+						{
+							ID:       "device-1",
+							Name:     "Device One",
+							Hostname: "device-one",
+							User:     "user-1",
+							OS:       "linux",
+							ClientConnectivity: &tailscale.ClientConnectivity{
+								DERPLatency: map[string]tailscale.DERPRegion{
+									"nyc": {LatencyMilliseconds: 20},
+									"lax": {LatencyMilliseconds: 80},
+								},
+							},
+						},
+						{
+							ID:       "device-2",
+							Name:     "Device Two",
+							Hostname: "device-two",
+							User:     "user-2",
+							OS:       "macOS",
+							ClientConnectivity: &tailscale.ClientConnectivity{
+								DERPLatency: map[string]tailscale.DERPRegion{
+									"nyc": {LatencyMilliseconds: 60},
+								},
+							},
+						},
+					},
+				},
+			},
+			metricNames: []string{
+				"tailscale_devices_latency_ms",
+				"tailscale_devices_best_latency_ms",
+				"tailscale_devices_derp_latency_ms",
+			},
+			expectedMetrics: `
+# HELP tailscale_devices_best_latency_ms Device latency to its lowest-latency DERP region in milliseconds
+# TYPE tailscale_devices_best_latency_ms gauge
+tailscale_devices_best_latency_ms{derp_region="nyc",hostname="device-one",id="device-1",name="Device One",os="linux",user="user-1"} 20
+tailscale_devices_best_latency_ms{derp_region="nyc",hostname="device-two",id="device-2",name="Device Two",os="macOS",user="user-2"} 60
+# HELP tailscale_devices_derp_latency_ms Distribution of device latency to each DERP region in milliseconds
+# TYPE tailscale_devices_derp_latency_ms histogram
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="5"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="10"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="25"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="50"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="75"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="100"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="150"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="200"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="300"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="500"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="1000"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="lax",le="+Inf"} 1
+tailscale_devices_derp_latency_ms_sum{derp_region="lax"} 80
+tailscale_devices_derp_latency_ms_count{derp_region="lax"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="5"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="10"} 0
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="25"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="50"} 1
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="75"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="100"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="150"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="200"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="300"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="500"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="1000"} 2
+tailscale_devices_derp_latency_ms_bucket{derp_region="nyc",le="+Inf"} 2
+tailscale_devices_derp_latency_ms_sum{derp_region="nyc"} 80
+tailscale_devices_derp_latency_ms_count{derp_region="nyc"} 2
+`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			collector := &TailscaleDevicesCollector{
-				log: logger,
+				log:             logger,
+				derpLatencyMode: tt.derpLatencyMode,
 			}
 
-			// Buffer must be >= number of metrics emitted per device (currently 12) to avoid blocking Update.
+			// Buffer must be >= number of metrics emitted per device (currently 25) to avoid blocking Update.
 			// Using a generous buffer to be resilient to future additions.
-			ch := make(chan prometheus.Metric, 32)
+			ch := make(chan prometheus.Metric, 64)
 			ctx := context.Background()
 
 			err := collector.Update(ctx, tt.mockClient, ch)
@@ -155,6 +264,7 @@ tailscale_devices_routes_enabled{hostname="device-one",id="device-123",name="Dev
 			if err := testutil.GatherAndCompare(
 				reg,
 				strings.NewReader(tt.expectedMetrics),
+				tt.metricNames...,
 			); err != nil {
 				t.Errorf("metrics mismatch: %v", err)
 			}
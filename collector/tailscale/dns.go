@@ -2,9 +2,13 @@ package tailscale
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"log/slog"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
 )
 
 const dnsSubsystem = "dns"
@@ -22,6 +26,30 @@ var (
 		"Tailscale Magic DNS configuration.",
 		[]string{},
 	)
+	dnsSplitDNSNameserversDesc = newDesc(
+		dnsSubsystem,
+		"split_dns_nameservers_info",
+		"Tailscale split DNS nameservers per domain.",
+		[]string{"domain", "nameserver"},
+	)
+	dnsSearchPathsDesc = newDesc(
+		dnsSubsystem,
+		"search_paths_info",
+		"Tailscale DNS search paths.",
+		[]string{"search_path"},
+	)
+	dnsOverrideLocalDNSDesc = newDesc(
+		dnsSubsystem,
+		"override_local_dns",
+		"Whether Tailscale overrides the local DNS configuration of devices.",
+		[]string{},
+	)
+	dnsConfigurationHashDesc = newDesc(
+		dnsSubsystem,
+		"configuration_hash",
+		"Hash of the Tailscale DNS configuration. Changes whenever the configuration changes.",
+		[]string{},
+	)
 )
 
 type TailscaleDNSCollector struct {
@@ -83,5 +111,65 @@ func (c TailscaleDNSCollector) Update(
 		boolAsFloat(magicDns.MagicDNS),
 	)
 
+	config, err := client.DNS().Configuration(ctx)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale dns configuration",
+			"error",
+			err.Error(),
+		)
+		return err
+	}
+
+	for domain, resolvers := range config.SplitDNS {
+		for _, resolver := range resolvers {
+			ch <- prometheus.MustNewConstMetric(
+				dnsSplitDNSNameserversDesc,
+				prometheus.GaugeValue,
+				1,
+				domain,
+				resolver.Address,
+			)
+		}
+	}
+
+	for _, searchPath := range config.SearchPaths {
+		ch <- prometheus.MustNewConstMetric(
+			dnsSearchPathsDesc,
+			prometheus.GaugeValue,
+			1,
+			searchPath,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		dnsOverrideLocalDNSDesc,
+		prometheus.GaugeValue,
+		boolAsFloat(config.Preferences.OverrideLocalDNS),
+	)
+
+	hash, err := dnsConfigurationHash(config)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(
+		dnsConfigurationHashDesc,
+		prometheus.GaugeValue,
+		hash,
+	)
+
 	return nil
 }
+
+// dnsConfigurationHash hashes the JSON encoding of the DNS configuration, which
+// is stable since map keys are sorted. The first 48 bits of the SHA-256 hash are
+// returned so the value is exactly representable as a float64.
+func dnsConfigurationHash(config *tailscale.DNSConfiguration) (float64, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+	return float64(binary.BigEndian.Uint64(sum[:8]) >> 16), nil
+}
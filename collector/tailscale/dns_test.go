@@ -28,6 +28,16 @@ func TestTailscaleDNSCollector_Update(t *testing.T) {
 					preferences: &tailscale.DNSPreferences{
 						MagicDNS: true,
 					},
+					configuration: &tailscale.DNSConfiguration{
+						SplitDNS: map[string][]tailscale.DNSConfigurationResolver{
+							"corp.example.com": {{Address: "10.0.0.53"}, {Address: "10.0.1.53"}},
+						},
+						SearchPaths: []string{"corp.example.com"},
+						Preferences: tailscale.DNSConfigurationPreferences{
+							OverrideLocalDNS: true,
+							MagicDNS:         true,
+						},
+					},
 				},
 			},
 			expectedMetrics: `
@@ -39,6 +49,19 @@ tailscale_dns_nameservers_info{nameserver="8.8.8.8"} 1
 # HELP tailscale_dns_magic_dns Tailscale Magic DNS configuration.
 # TYPE tailscale_dns_magic_dns gauge
 tailscale_dns_magic_dns 1
+# HELP tailscale_dns_split_dns_nameservers_info Tailscale split DNS nameservers per domain.
+# TYPE tailscale_dns_split_dns_nameservers_info gauge
+tailscale_dns_split_dns_nameservers_info{domain="corp.example.com",nameserver="10.0.0.53"} 1
+tailscale_dns_split_dns_nameservers_info{domain="corp.example.com",nameserver="10.0.1.53"} 1
+# HELP tailscale_dns_search_paths_info Tailscale DNS search paths.
+# TYPE tailscale_dns_search_paths_info gauge
+tailscale_dns_search_paths_info{search_path="corp.example.com"} 1
+# HELP tailscale_dns_override_local_dns Whether Tailscale overrides the local DNS configuration of devices.
+# TYPE tailscale_dns_override_local_dns gauge
+tailscale_dns_override_local_dns 1
+# HELP tailscale_dns_configuration_hash Hash of the Tailscale DNS configuration. Changes whenever the configuration changes.
+# TYPE tailscale_dns_configuration_hash gauge
+tailscale_dns_configuration_hash 2.43563470704794e+14
 `,
 			expectError: false,
 		},
@@ -0,0 +1,293 @@
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/sync/errgroup"
+)
+
+const dnsProbeSubsystem = "dns_probe"
+
+const (
+	defaultDNSProbeQueryType = "A"
+	defaultDNSProbeTimeout   = 2 * time.Second
+	dnsProbePort             = "53"
+	// dnsProbeMaxResponseSize is the largest UDP response read, matching the
+	// commonly used EDNS buffer size.
+	dnsProbeMaxResponseSize = 1232
+	// dnsProbeConcurrency is the number of nameservers probed at once.
+	dnsProbeConcurrency = 8
+)
+
+var dnsProbeQueryTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"SOA":   dnsmessage.TypeSOA,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+var (
+	dnsProbeSuccessDesc = newDesc(
+		dnsProbeSubsystem,
+		"success",
+		"Whether the nameserver answered the probe query. Domain is empty for global nameservers.",
+		[]string{"nameserver", "domain"},
+	)
+	dnsProbeRcodeDesc = newDesc(
+		dnsProbeSubsystem,
+		"rcode",
+		"Response code returned by the nameserver for the probe query.",
+		[]string{"nameserver", "domain", "rcode"},
+	)
+	dnsProbeDurationDesc = newDesc(
+		dnsProbeSubsystem,
+		"duration_seconds",
+		"Round trip time of the probe query.",
+		[]string{"nameserver", "domain"},
+	)
+)
+
+// dnsProbeTarget is a nameserver to probe and the name to query it for.
+type dnsProbeTarget struct {
+	nameserver string
+	domain     string
+	queryName  string
+}
+
+type TailscaleDNSProbeCollector struct {
+	log       *slog.Logger
+	queryName string
+	queryType dnsmessage.Type
+	timeout   time.Duration
+	port      string
+}
+
+func init() {
+	registerCollector(dnsProbeSubsystem, NewTailscaleDNSProbeCollector)
+}
+
+// NewTailscaleDNSProbeCollector creates the DNS probe collector. It is only
+// enabled when a probe query is configured. Global nameservers are probed with
+// the configured query name, split DNS nameservers with their domain.
+func NewTailscaleDNSProbeCollector(config collectorConfig) (Collector, error) {
+	if config.DNSProbeQuery == "" {
+		return nil, nil
+	}
+
+	queryTypeName := strings.ToUpper(config.DNSProbeQueryType)
+	if queryTypeName == "" {
+		queryTypeName = defaultDNSProbeQueryType
+	}
+	queryType, ok := dnsProbeQueryTypes[queryTypeName]
+	if !ok {
+		return nil, fmt.Errorf("invalid DNS probe query type %q", config.DNSProbeQueryType)
+	}
+
+	timeout := config.DNSProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultDNSProbeTimeout
+	}
+
+	return &TailscaleDNSProbeCollector{
+		log:       config.logger,
+		queryName: config.DNSProbeQuery,
+		queryType: queryType,
+		timeout:   timeout,
+		port:      dnsProbePort,
+	}, nil
+}
+
+func (c TailscaleDNSProbeCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting dns probe metrics")
+
+	config, err := client.DNS().Configuration(ctx)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale dns configuration",
+			"error",
+			err.Error(),
+		)
+		return err
+	}
+
+	// A nameserver listed more than once is probed once, as every probe of
+	// it reports the same series.
+	var targets []dnsProbeTarget
+	seen := make(map[dnsProbeTarget]bool)
+	addTarget := func(target dnsProbeTarget) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	for _, resolver := range config.Nameservers {
+		addTarget(dnsProbeTarget{
+			nameserver: resolver.Address,
+			queryName:  c.queryName,
+		})
+	}
+	for domain, resolvers := range config.SplitDNS {
+		for _, resolver := range resolvers {
+			addTarget(dnsProbeTarget{
+				nameserver: resolver.Address,
+				domain:     domain,
+				queryName:  domain,
+			})
+		}
+	}
+
+	group := errgroup.Group{}
+	group.SetLimit(dnsProbeConcurrency)
+	for _, target := range targets {
+		server, ok := c.serverAddr(target.nameserver)
+		if !ok {
+			c.log.DebugContext(ctx, "Skipping DNS probe of non-IP nameserver", "nameserver", target.nameserver)
+			continue
+		}
+
+		group.Go(func() error {
+			rcode, rtt, err := c.probe(ctx, server, target.queryName)
+			if err != nil {
+				c.log.WarnContext(ctx, "DNS probe failed",
+					"nameserver", target.nameserver,
+					"domain", target.domain,
+					"error", err.Error(),
+				)
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				dnsProbeSuccessDesc, prometheus.GaugeValue, boolAsFloat(err == nil),
+				target.nameserver, target.domain,
+			)
+			if err != nil {
+				return nil
+			}
+			ch <- prometheus.MustNewConstMetric(
+				dnsProbeRcodeDesc, prometheus.GaugeValue, 1,
+				target.nameserver, target.domain, dnsRcodeName(rcode),
+			)
+			ch <- prometheus.MustNewConstMetric(
+				dnsProbeDurationDesc, prometheus.GaugeValue, rtt.Seconds(),
+				target.nameserver, target.domain,
+			)
+			return nil
+		})
+	}
+	// Failed probes are reported as metrics, not errors.
+	_ = group.Wait()
+
+	return nil
+}
+
+// serverAddr returns the UDP address to probe for a nameserver. Nameservers
+// that are not plain IP addresses, such as DNS-over-HTTPS URLs, are skipped.
+func (c TailscaleDNSProbeCollector) serverAddr(nameserver string) (string, bool) {
+	if addrPort, err := netip.ParseAddrPort(nameserver); err == nil {
+		return addrPort.String(), true
+	}
+	if addr, err := netip.ParseAddr(nameserver); err == nil {
+		return net.JoinHostPort(addr.String(), c.port), true
+	}
+	return "", false
+}
+
+// probe sends a single query over UDP and returns the response code and round
+// trip time.
+func (c TailscaleDNSProbeCollector) probe(
+	ctx context.Context,
+	server, queryName string,
+) (dnsmessage.RCode, time.Duration, error) {
+	if !strings.HasSuffix(queryName, ".") {
+		queryName += "."
+	}
+	name, err := dnsmessage.NewName(queryName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: c.queryType, Class: dnsmessage.ClassINET},
+		},
+	}).Pack()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	begin := time.Now()
+	if _, err := conn.Write(query); err != nil {
+		return 0, 0, err
+	}
+
+	buf := make([]byte, dnsProbeMaxResponseSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, 0, err
+		}
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil {
+			return 0, 0, err
+		}
+		if header.ID != id || !header.Response {
+			// Ignore stray packets, e.g. late responses to an earlier probe.
+			continue
+		}
+		return header.RCode, time.Since(begin), nil
+	}
+}
+
+// dnsRcodeName returns the conventional name of a response code, e.g. NOERROR.
+func dnsRcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	default:
+		return fmt.Sprintf("RCODE%d", rcode)
+	}
+}
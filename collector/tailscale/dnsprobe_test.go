@@ -0,0 +1,137 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/dns/dnsmessage"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// startTestDNSServer starts a local DNS stand-in that answers every query with
+// the response code of the matching zone and NXDOMAIN otherwise.
+func startTestDNSServer(t *testing.T, zones map[string]dnsmessage.RCode) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			rcode, ok := zones[query.Questions[0].Name.String()]
+			if !ok {
+				rcode = dnsmessage.RCodeNameError
+			}
+			resp, err := (&dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode},
+				Questions: query.Questions,
+			}).Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestTailscaleDNSProbeCollector_Update(t *testing.T) {
+	port := startTestDNSServer(t, map[string]dnsmessage.RCode{
+		"tailscale.com.":    dnsmessage.RCodeSuccess,
+		"corp.example.com.": dnsmessage.RCodeRefused,
+	})
+
+	coll, err := NewTailscaleDNSProbeCollector(collectorConfig{
+		logger: slog.Default(),
+		Config: Config{DNSProbeQuery: "tailscale.com", DNSProbeTimeout: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("failed to create dns probe collector: %v", err)
+	}
+	collector := coll.(*TailscaleDNSProbeCollector)
+	collector.port = port
+
+	ch := make(chan prometheus.Metric, 16)
+	err = collector.Update(context.Background(), &MockTailscaleClient{
+		dnsClient: &MockDNSClient{
+			configuration: &tailscale.DNSConfiguration{
+				Nameservers: []tailscale.DNSConfigurationResolver{
+					{Address: "127.0.0.1"},
+					{Address: "https://dns.example.com/dns-query"},
+					// Listed twice, probed once.
+					{Address: "127.0.0.1"},
+				},
+				SplitDNS: map[string][]tailscale.DNSConfigurationResolver{
+					"corp.example.com": {{Address: "127.0.0.1"}, {Address: "127.0.0.1"}},
+				},
+			},
+		},
+	}, ch)
+	close(ch)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP tailscale_dns_probe_rcode Response code returned by the nameserver for the probe query.
+# TYPE tailscale_dns_probe_rcode gauge
+tailscale_dns_probe_rcode{domain="",nameserver="127.0.0.1",rcode="NOERROR"} 1
+tailscale_dns_probe_rcode{domain="corp.example.com",nameserver="127.0.0.1",rcode="REFUSED"} 1
+# HELP tailscale_dns_probe_success Whether the nameserver answered the probe query. Domain is empty for global nameservers.
+# TYPE tailscale_dns_probe_success gauge
+tailscale_dns_probe_success{domain="",nameserver="127.0.0.1"} 1
+tailscale_dns_probe_success{domain="corp.example.com",nameserver="127.0.0.1"} 1
+`), "tailscale_dns_probe_rcode", "tailscale_dns_probe_success"); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+	if count := testutil.CollectAndCount(reg, "tailscale_dns_probe_duration_seconds"); count != 2 {
+		t.Errorf("duration metrics = %d, want 2", count)
+	}
+}
+
+func TestTailscaleDNSProbeCollector_Timeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	collector := TailscaleDNSProbeCollector{
+		queryType: dnsmessage.TypeA,
+		timeout:   50 * time.Millisecond,
+	}
+	if _, _, err := collector.probe(context.Background(), conn.LocalAddr().String(), "tailscale.com"); err == nil {
+		t.Errorf("expected probe of unresponsive nameserver to fail")
+	}
+}
@@ -0,0 +1,305 @@
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
+)
+
+const flowLogsSubsystem = "flowlogs"
+
+// Flow log aggregation modes. They select what the source and destination
+// labels of the flow log traffic counters identify.
+const (
+	FlowLogsAggregateDevice = "device"
+	FlowLogsAggregateTag    = "tag"
+	FlowLogsAggregateUser   = "user"
+)
+
+const (
+	// flowLogsWindow bounds each network log request.
+	flowLogsWindow = time.Hour
+	// flowLogsRetention is how far back network logs are requested.
+	flowLogsRetention = 24 * time.Hour
+	// flowLogsDelay leaves time for recently captured logs to become
+	// queryable before their window is read.
+	flowLogsDelay = time.Minute
+)
+
+const (
+	flowLogsExternalPeer = "external"
+	flowLogsUnknownPeer  = "unknown"
+	// flowLogsUntaggedPeer is the tag label of devices without tags, which
+	// would otherwise share the empty destination of physical traffic.
+	flowLogsUntaggedPeer = "untagged"
+)
+
+// derpMagicAddr is the address physical traffic is reported to when it is
+// relayed through DERP instead of sent directly to the peer.
+var derpMagicAddr = netip.MustParseAddr("127.3.3.40")
+
+var (
+	flowLogsBytesDesc = newDesc(
+		flowLogsSubsystem,
+		"bytes_total",
+		"Number of bytes recorded in network flow logs.",
+		[]string{"source", "destination", "traffic_type", "direction"},
+	)
+	flowLogsPacketsDesc = newDesc(
+		flowLogsSubsystem,
+		"packets_total",
+		"Number of packets recorded in network flow logs.",
+		[]string{"source", "destination", "traffic_type", "direction"},
+	)
+	flowLogsEntriesDesc = newDesc(
+		flowLogsSubsystem,
+		"entries_total",
+		"Number of network flow log entries read.",
+		nil,
+	)
+	flowLogsCursorDesc = newDesc(
+		flowLogsSubsystem,
+		"cursor_timestamp",
+		"Unix timestamp up to which network flow logs have been read.",
+		nil,
+	)
+)
+
+type flowLogsKey struct {
+	source      string
+	destination string
+	trafficType string
+	direction   string
+}
+
+type TailscaleFlowLogsCollector struct {
+	log         *slog.Logger
+	aggregation string
+	now         func() time.Time
+
+	mtx    sync.Mutex
+	cursor time.Time
+	counts flowLogsCounts
+}
+
+// flowLogsCounts holds the traffic counters of network flow logs.
+type flowLogsCounts struct {
+	entries float64
+	bytes   map[flowLogsKey]float64
+	packets map[flowLogsKey]float64
+}
+
+func newFlowLogsCounts() flowLogsCounts {
+	return flowLogsCounts{
+		bytes:   make(map[flowLogsKey]float64),
+		packets: make(map[flowLogsKey]float64),
+	}
+}
+
+func init() {
+	registerCollector(flowLogsSubsystem, NewTailscaleFlowLogsCollector)
+}
+
+// NewTailscaleFlowLogsCollector creates the flow logs collector. It is only
+// enabled when a flow log aggregation mode is configured.
+func NewTailscaleFlowLogsCollector(config collectorConfig) (Collector, error) {
+	switch config.FlowLogsAggregation {
+	case "":
+		return nil, nil
+	case FlowLogsAggregateDevice, FlowLogsAggregateTag, FlowLogsAggregateUser:
+	default:
+		return nil, fmt.Errorf("invalid flow logs aggregation %q", config.FlowLogsAggregation)
+	}
+
+	return &TailscaleFlowLogsCollector{
+		log:         config.logger,
+		aggregation: config.FlowLogsAggregation,
+		now:         time.Now,
+		cursor:      time.Now(),
+		counts:      newFlowLogsCounts(),
+	}, nil
+}
+
+func (c *TailscaleFlowLogsCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting flow logs metrics")
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	err := c.readLogs(ctx, client)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale network flow logs; ensure the OAuth client has logs:network:read",
+			"error",
+			err.Error(),
+		)
+	}
+
+	for key, value := range c.counts.bytes {
+		ch <- prometheus.MustNewConstMetric(
+			flowLogsBytesDesc, prometheus.CounterValue, value,
+			key.source, key.destination, key.trafficType, key.direction,
+		)
+	}
+	for key, value := range c.counts.packets {
+		ch <- prometheus.MustNewConstMetric(
+			flowLogsPacketsDesc, prometheus.CounterValue, value,
+			key.source, key.destination, key.trafficType, key.direction,
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(flowLogsEntriesDesc, prometheus.CounterValue, c.counts.entries)
+	ch <- prometheus.MustNewConstMetric(
+		flowLogsCursorDesc, prometheus.GaugeValue, float64(c.cursor.Unix()),
+	)
+
+	return err
+}
+
+func (c *TailscaleFlowLogsCollector) readLogs(ctx context.Context, client TailscaleClient) error {
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return err
+	}
+	peers := newFlowLogsPeers(devices, c.aggregation)
+
+	return readLogWindows(&c.cursor, c.now().Add(-flowLogsDelay), flowLogsWindow, flowLogsRetention,
+		func(start, end time.Time) error {
+			// A window that fails is read again from its start, so its logs
+			// are only counted once it was read completely.
+			window := newFlowLogsCounts()
+			err := client.Logging().GetNetworkFlowLogs(ctx,
+				tailscale.NetworkFlowLogsRequest{Start: start, End: end},
+				func(log tailscale.NetworkFlowLog) error {
+					// The start of the window is inclusive, skip logs already counted.
+					if !log.Logged.After(start) || log.Logged.After(end) {
+						return nil
+					}
+					window.entries++
+					window.add(peers, log)
+					return nil
+				},
+			)
+			if err != nil {
+				return err
+			}
+			c.counts.merge(window)
+			return nil
+		},
+	)
+}
+
+func (c *flowLogsCounts) add(peers flowLogsPeers, log tailscale.NetworkFlowLog) {
+	source := peers.byNodeID(log.NodeID)
+
+	for trafficType, traffic := range map[string][]tailscale.TrafficStats{
+		"virtual": log.VirtualTraffic,
+		"subnet":  log.SubnetTraffic,
+		"exit":    log.ExitTraffic,
+	} {
+		for _, stats := range traffic {
+			c.addStats(source, peers.byAddr(stats.Dst, trafficType != "virtual"), trafficType, stats)
+		}
+	}
+
+	for _, stats := range log.PhysicalTraffic {
+		trafficType := "physical_direct"
+		if addr, err := netip.ParseAddrPort(stats.Dst); err == nil && addr.Addr() == derpMagicAddr {
+			trafficType = "physical_relayed"
+		}
+		c.addStats(source, "", trafficType, stats)
+	}
+}
+
+func (c *flowLogsCounts) addStats(
+	source, destination, trafficType string,
+	stats tailscale.TrafficStats,
+) {
+	tx := flowLogsKey{source, destination, trafficType, "tx"}
+	rx := flowLogsKey{source, destination, trafficType, "rx"}
+	c.bytes[tx] += float64(stats.TxBytes)
+	c.bytes[rx] += float64(stats.RxBytes)
+	c.packets[tx] += float64(stats.TxPkts)
+	c.packets[rx] += float64(stats.RxPkts)
+}
+
+func (c *flowLogsCounts) merge(other flowLogsCounts) {
+	c.entries += other.entries
+	for key, value := range other.bytes {
+		c.bytes[key] += value
+	}
+	for key, value := range other.packets {
+		c.packets[key] += value
+	}
+}
+
+// flowLogsPeers resolves node IDs and tailnet addresses found in flow logs to
+// the label value of the configured aggregation.
+type flowLogsPeers struct {
+	nodes map[string]string
+	addrs map[netip.Addr]string
+}
+
+func newFlowLogsPeers(devices []tailscale.Device, aggregation string) flowLogsPeers {
+	peers := flowLogsPeers{
+		nodes: make(map[string]string, len(devices)),
+		addrs: make(map[netip.Addr]string, len(devices)*2),
+	}
+	for _, device := range devices {
+		var label string
+		switch aggregation {
+		case FlowLogsAggregateTag:
+			tags := slices.Clone(device.Tags)
+			slices.Sort(tags)
+			label = strings.Join(tags, ",")
+			if label == "" {
+				label = flowLogsUntaggedPeer
+			}
+		case FlowLogsAggregateUser:
+			label = device.User
+		default:
+			label = device.Name
+		}
+
+		peers.nodes[device.NodeID] = label
+		for _, raw := range device.Addresses {
+			if addr, err := netip.ParseAddr(raw); err == nil {
+				peers.addrs[addr.Unmap()] = label
+			}
+		}
+	}
+	return peers
+}
+
+func (p flowLogsPeers) byNodeID(nodeID string) string {
+	if label, ok := p.nodes[nodeID]; ok {
+		return label
+	}
+	return flowLogsUnknownPeer
+}
+
+// byAddr resolves the destination of a flow. Destinations outside the tailnet
+// are reported as external for subnet and exit traffic.
+func (p flowLogsPeers) byAddr(addrPort string, external bool) string {
+	if addr, err := netip.ParseAddrPort(addrPort); err == nil {
+		if label, ok := p.addrs[addr.Addr().Unmap()]; ok {
+			return label
+		}
+	}
+	if external {
+		return flowLogsExternalPeer
+	}
+	return flowLogsUnknownPeer
+}
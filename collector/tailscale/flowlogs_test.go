@@ -0,0 +1,252 @@
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleFlowLogsCollector_Update(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+
+	devices := []tailscale.Device{
+		{
+			NodeID:    "n1",
+			Name:      "web.example.ts.net",
+			User:      "alice@example.com",
+			Tags:      []string{"tag:web", "tag:prod"},
+			Addresses: []string{"100.64.0.1"},
+		},
+		{
+			NodeID:    "n2",
+			Name:      "db.example.ts.net",
+			User:      "bob@example.com",
+			Tags:      []string{"tag:db"},
+			Addresses: []string{"100.64.0.2"},
+		},
+		{
+			NodeID:    "n3",
+			Name:      "laptop.example.ts.net",
+			User:      "alice@example.com",
+			Addresses: []string{"100.64.0.3"},
+		},
+	}
+	flowLogs := []tailscale.NetworkFlowLog{
+		{
+			Logged: start.Add(time.Minute),
+			NodeID: "n1",
+			VirtualTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.1:4000", Dst: "100.64.0.2:5432", TxBytes: 100, RxBytes: 200, TxPkts: 1, RxPkts: 2},
+			},
+			ExitTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.1:4001", Dst: "198.51.100.1:443", TxBytes: 10, RxBytes: 20, TxPkts: 1, RxPkts: 1},
+			},
+			PhysicalTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.2:0", Dst: "127.3.3.40:1", TxBytes: 150, RxBytes: 250, TxPkts: 3, RxPkts: 4},
+			},
+		},
+		{
+			Logged: start.Add(2 * time.Minute),
+			NodeID: "n1",
+			VirtualTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.1:4000", Dst: "100.64.0.2:5432", TxBytes: 50, RxBytes: 50, TxPkts: 1, RxPkts: 1},
+			},
+		},
+		{
+			Logged: start.Add(3 * time.Minute),
+			NodeID: "n3",
+			VirtualTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.3:4002", Dst: "100.64.0.1:443", TxBytes: 30, RxBytes: 40, TxPkts: 1, RxPkts: 1},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		aggregation     string
+		metricNames     []string
+		expectedMetrics string
+	}{
+		{
+			name:        "aggregated by device",
+			aggregation: FlowLogsAggregateDevice,
+			expectedMetrics: `
+# HELP tailscale_flowlogs_bytes_total Number of bytes recorded in network flow logs.
+# TYPE tailscale_flowlogs_bytes_total counter
+tailscale_flowlogs_bytes_total{destination="",direction="rx",source="web.example.ts.net",traffic_type="physical_relayed"} 250
+tailscale_flowlogs_bytes_total{destination="",direction="tx",source="web.example.ts.net",traffic_type="physical_relayed"} 150
+tailscale_flowlogs_bytes_total{destination="db.example.ts.net",direction="rx",source="web.example.ts.net",traffic_type="virtual"} 250
+tailscale_flowlogs_bytes_total{destination="db.example.ts.net",direction="tx",source="web.example.ts.net",traffic_type="virtual"} 150
+tailscale_flowlogs_bytes_total{destination="external",direction="rx",source="web.example.ts.net",traffic_type="exit"} 20
+tailscale_flowlogs_bytes_total{destination="external",direction="tx",source="web.example.ts.net",traffic_type="exit"} 10
+tailscale_flowlogs_bytes_total{destination="web.example.ts.net",direction="rx",source="laptop.example.ts.net",traffic_type="virtual"} 40
+tailscale_flowlogs_bytes_total{destination="web.example.ts.net",direction="tx",source="laptop.example.ts.net",traffic_type="virtual"} 30
+# HELP tailscale_flowlogs_packets_total Number of packets recorded in network flow logs.
+# TYPE tailscale_flowlogs_packets_total counter
+tailscale_flowlogs_packets_total{destination="",direction="rx",source="web.example.ts.net",traffic_type="physical_relayed"} 4
+tailscale_flowlogs_packets_total{destination="",direction="tx",source="web.example.ts.net",traffic_type="physical_relayed"} 3
+tailscale_flowlogs_packets_total{destination="db.example.ts.net",direction="rx",source="web.example.ts.net",traffic_type="virtual"} 3
+tailscale_flowlogs_packets_total{destination="db.example.ts.net",direction="tx",source="web.example.ts.net",traffic_type="virtual"} 2
+tailscale_flowlogs_packets_total{destination="external",direction="rx",source="web.example.ts.net",traffic_type="exit"} 1
+tailscale_flowlogs_packets_total{destination="external",direction="tx",source="web.example.ts.net",traffic_type="exit"} 1
+tailscale_flowlogs_packets_total{destination="web.example.ts.net",direction="rx",source="laptop.example.ts.net",traffic_type="virtual"} 1
+tailscale_flowlogs_packets_total{destination="web.example.ts.net",direction="tx",source="laptop.example.ts.net",traffic_type="virtual"} 1
+# HELP tailscale_flowlogs_entries_total Number of network flow log entries read.
+# TYPE tailscale_flowlogs_entries_total counter
+tailscale_flowlogs_entries_total 3
+# HELP tailscale_flowlogs_cursor_timestamp Unix timestamp up to which network flow logs have been read.
+# TYPE tailscale_flowlogs_cursor_timestamp gauge
+tailscale_flowlogs_cursor_timestamp 1.7000003e+09
+`,
+		},
+		{
+			name:        "aggregated by tag",
+			aggregation: FlowLogsAggregateTag,
+			metricNames: []string{"tailscale_flowlogs_bytes_total"},
+			expectedMetrics: `
+# HELP tailscale_flowlogs_bytes_total Number of bytes recorded in network flow logs.
+# TYPE tailscale_flowlogs_bytes_total counter
+tailscale_flowlogs_bytes_total{destination="",direction="rx",source="tag:prod,tag:web",traffic_type="physical_relayed"} 250
+tailscale_flowlogs_bytes_total{destination="",direction="tx",source="tag:prod,tag:web",traffic_type="physical_relayed"} 150
+tailscale_flowlogs_bytes_total{destination="external",direction="rx",source="tag:prod,tag:web",traffic_type="exit"} 20
+tailscale_flowlogs_bytes_total{destination="external",direction="tx",source="tag:prod,tag:web",traffic_type="exit"} 10
+tailscale_flowlogs_bytes_total{destination="tag:db",direction="rx",source="tag:prod,tag:web",traffic_type="virtual"} 250
+tailscale_flowlogs_bytes_total{destination="tag:db",direction="tx",source="tag:prod,tag:web",traffic_type="virtual"} 150
+tailscale_flowlogs_bytes_total{destination="tag:prod,tag:web",direction="rx",source="untagged",traffic_type="virtual"} 40
+tailscale_flowlogs_bytes_total{destination="tag:prod,tag:web",direction="tx",source="untagged",traffic_type="virtual"} 30
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll, err := NewTailscaleFlowLogsCollector(collectorConfig{
+				logger: slog.Default(),
+				Config: Config{FlowLogsAggregation: tt.aggregation},
+			})
+			if err != nil {
+				t.Fatalf("failed to create flow logs collector: %v", err)
+			}
+			collector := coll.(*TailscaleFlowLogsCollector)
+			collector.cursor = start
+			now := start.Add(5 * time.Minute)
+			collector.now = func() time.Time { return now }
+
+			client := &MockTailscaleClient{
+				devicesClient: &MockDevicesClient{devices: devices},
+				loggingClient: &MockLoggingClient{flowLogs: flowLogs},
+			}
+
+			// Reading again must not count the same logs twice.
+			var metrics []prometheus.Metric
+			for range 2 {
+				ch := make(chan prometheus.Metric, 32)
+				if err := collector.Update(context.Background(), client, ch); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				close(ch)
+
+				metrics = nil
+				for metric := range ch {
+					metrics = append(metrics, metric)
+				}
+				now = now.Add(time.Minute)
+			}
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&TestMetricCollector{metrics: metrics})
+			if err := testutil.GatherAndCompare(
+				reg,
+				strings.NewReader(tt.expectedMetrics),
+				tt.metricNames...,
+			); err != nil {
+				t.Errorf("metrics mismatch: %v", err)
+			}
+		})
+	}
+}
+
+func TestTailscaleFlowLogsCollector_FailedWindow(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+
+	coll, err := NewTailscaleFlowLogsCollector(collectorConfig{
+		logger: slog.Default(),
+		Config: Config{FlowLogsAggregation: FlowLogsAggregateDevice},
+	})
+	if err != nil {
+		t.Fatalf("failed to create flow logs collector: %v", err)
+	}
+	collector := coll.(*TailscaleFlowLogsCollector)
+	collector.cursor = start
+	collector.now = func() time.Time { return start.Add(5 * time.Minute) }
+
+	logging := &MockLoggingClient{
+		flowLogs: []tailscale.NetworkFlowLog{{
+			Logged: start.Add(time.Minute),
+			NodeID: "n1",
+			VirtualTraffic: []tailscale.TrafficStats{
+				{Src: "100.64.0.1:4000", Dst: "100.64.0.2:5432", TxBytes: 100, RxBytes: 200, TxPkts: 1, RxPkts: 2},
+			},
+		}},
+		flowLogsErr: errors.New("connection reset"),
+	}
+	client := &MockTailscaleClient{
+		devicesClient: &MockDevicesClient{},
+		loggingClient: logging,
+	}
+
+	// The window fails after its logs were streamed and is read again by
+	// the next scrape, which must count them once.
+	var metrics []prometheus.Metric
+	for _, expectErr := range []bool{true, false} {
+		ch := make(chan prometheus.Metric, 32)
+		err := collector.Update(context.Background(), client, ch)
+		close(ch)
+		if (err != nil) != expectErr {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		metrics = nil
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+		logging.flowLogsErr = nil
+	}
+
+	expected := `
+# HELP tailscale_flowlogs_entries_total Number of network flow log entries read.
+# TYPE tailscale_flowlogs_entries_total counter
+tailscale_flowlogs_entries_total 1
+# HELP tailscale_flowlogs_packets_total Number of packets recorded in network flow logs.
+# TYPE tailscale_flowlogs_packets_total counter
+tailscale_flowlogs_packets_total{destination="unknown",direction="rx",source="unknown",traffic_type="virtual"} 2
+tailscale_flowlogs_packets_total{destination="unknown",direction="tx",source="unknown",traffic_type="virtual"} 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(
+		reg,
+		strings.NewReader(expected),
+		"tailscale_flowlogs_entries_total",
+		"tailscale_flowlogs_packets_total",
+	); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
+
+func TestNewTailscaleFlowLogsCollector_Disabled(t *testing.T) {
+	coll, err := NewTailscaleFlowLogsCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coll != nil {
+		t.Errorf("expected flow logs collector to be disabled")
+	}
+}
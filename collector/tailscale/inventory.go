@@ -0,0 +1,168 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
+)
+
+const inventorySubsystem = "inventory"
+
+const (
+	orphanReasonSuspended = "suspended"
+	orphanReasonUnknown   = "unknown"
+)
+
+var (
+	inventoryUserDevicesDesc = newDesc(
+		inventorySubsystem,
+		"user_devices",
+		"Number of devices owned by the user, devices owned by tags are not attributed to a user.",
+		[]string{"user_id", "login_name", "role", "status"},
+	)
+	inventoryRoleDevicesDesc = newDesc(
+		inventorySubsystem,
+		"role_devices",
+		"Number of devices owned by users with the role.",
+		[]string{"role"},
+	)
+	inventoryTaggedDevicesDesc = newDesc(
+		inventorySubsystem,
+		"tagged_devices",
+		"Number of devices owned by tags.",
+		nil,
+	)
+	inventoryOrphanedDeviceDesc = newDesc(
+		inventorySubsystem,
+		"orphaned_device",
+		"Device owned by a suspended user or by a user that is not part of the tailnet, deleted users are reported as unknown.",
+		[]string{"id", "name", "user", "reason"},
+	)
+	inventoryOrphanedDevicesDesc = newDesc(
+		inventorySubsystem,
+		"orphaned_devices",
+		"Number of devices owned by a suspended or unknown user.",
+		[]string{"reason"},
+	)
+	inventoryUserWithoutDevicesDesc = newDesc(
+		inventorySubsystem,
+		"user_without_devices",
+		"Member user that owns no devices in the tailnet.",
+		[]string{"user_id", "login_name", "role", "status"},
+	)
+)
+
+// TailscaleInventoryCollector joins devices with users to attribute devices
+// to their owners.
+type TailscaleInventoryCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(inventorySubsystem, NewTailscaleInventoryCollector)
+}
+
+func NewTailscaleInventoryCollector(config collectorConfig) (Collector, error) {
+	return &TailscaleInventoryCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c TailscaleInventoryCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting inventory metrics")
+
+	users, err := client.Users().List(ctx, nil, nil)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Tailscale users", "error", err.Error())
+		return err
+	}
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Tailscale devices", "error", err.Error())
+		return err
+	}
+
+	usersByLogin := make(map[string]tailscale.User, len(users))
+	for _, user := range users {
+		usersByLogin[user.LoginName] = user
+	}
+
+	userDevices := make(map[string]int, len(users))
+	roleDevices := make(map[tailscale.UserRole]int)
+	orphaned := map[string]int{
+		orphanReasonSuspended: 0,
+		orphanReasonUnknown:   0,
+	}
+	var tagged int
+
+	for _, device := range devices {
+		if len(device.Tags) > 0 {
+			tagged++
+			continue
+		}
+
+		user, ok := usersByLogin[device.User]
+		if !ok {
+			orphaned[orphanReasonUnknown]++
+			ch <- prometheus.MustNewConstMetric(
+				inventoryOrphanedDeviceDesc, prometheus.GaugeValue, 1,
+				device.ID, device.Name, device.User, orphanReasonUnknown,
+			)
+			continue
+		}
+
+		userDevices[user.LoginName]++
+		roleDevices[user.Role]++
+
+		if user.Status == tailscale.UserStatusSuspended {
+			orphaned[orphanReasonSuspended]++
+			ch <- prometheus.MustNewConstMetric(
+				inventoryOrphanedDeviceDesc, prometheus.GaugeValue, 1,
+				device.ID, device.Name, device.User, orphanReasonSuspended,
+			)
+		}
+	}
+
+	for _, user := range users {
+		count := userDevices[user.LoginName]
+		ch <- prometheus.MustNewConstMetric(
+			inventoryUserDevicesDesc, prometheus.GaugeValue, float64(count),
+			user.ID, user.LoginName, string(user.Role), string(user.Status),
+		)
+
+		// Shared users own their devices in another tailnet.
+		if count == 0 && user.Type == tailscale.UserTypeMember {
+			ch <- prometheus.MustNewConstMetric(
+				inventoryUserWithoutDevicesDesc, prometheus.GaugeValue, 1,
+				user.ID, user.LoginName, string(user.Role), string(user.Status),
+			)
+		}
+	}
+
+	for role, count := range roleDevices {
+		ch <- prometheus.MustNewConstMetric(
+			inventoryRoleDevicesDesc, prometheus.GaugeValue, float64(count),
+			string(role),
+		)
+	}
+
+	for reason, count := range orphaned {
+		ch <- prometheus.MustNewConstMetric(
+			inventoryOrphanedDevicesDesc, prometheus.GaugeValue, float64(count),
+			reason,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		inventoryTaggedDevicesDesc, prometheus.GaugeValue, float64(tagged),
+	)
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleInventoryCollector_Update(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name            string
+		mockClient      *MockTailscaleClient
+		expectedMetrics string
+		expectError     bool
+	}{
+		{
+			name: "joins devices with their owners",
+			mockClient: &MockTailscaleClient{
+				usersClient: &MockUsersClient{
+					users: []tailscale.User{
+						{
+							ID:        "user-1",
+							LoginName: "alice@example.com",
+							Type:      tailscale.UserTypeMember,
+							Role:      tailscale.UserRoleAdmin,
+							Status:    tailscale.UserStatusActive,
+						},
+						{
+							ID:        "user-2",
+							LoginName: "bob@example.com",
+							Type:      tailscale.UserTypeMember,
+							Role:      tailscale.UserRoleMember,
+							Status:    tailscale.UserStatusSuspended,
+						},
+						{
+							ID:        "user-3",
+							LoginName: "carol@example.com",
+							Type:      tailscale.UserTypeMember,
+							Role:      tailscale.UserRoleMember,
+							Status:    tailscale.UserStatusIdle,
+						},
+						{
+							ID:        "user-4",
+							LoginName: "dave@partner.com",
+							Type:      tailscale.UserTypeShared,
+							Role:      tailscale.UserRoleMember,
+							Status:    tailscale.UserStatusActive,
+						},
+					},
+				},
+				devicesClient: &MockDevicesClient{
+					devices: []tailscale.Device{
+						{ID: "dev-1", Name: "laptop", User: "alice@example.com"},
+						{ID: "dev-2", Name: "phone", User: "alice@example.com"},
+						{ID: "dev-3", Name: "desktop", User: "bob@example.com"},
+						{ID: "dev-4", Name: "old-server", User: "eve@example.com"},
+						{
+							ID:   "dev-5",
+							Name: "router",
+							User: "eve@example.com",
+							Tags: []string{"tag:router"},
+						},
+					},
+				},
+			},
+			expectedMetrics: `
+# HELP tailscale_inventory_orphaned_device Device owned by a suspended user or by a user that is not part of the tailnet, deleted users are reported as unknown.
+# TYPE tailscale_inventory_orphaned_device gauge
+tailscale_inventory_orphaned_device{id="dev-3",name="desktop",reason="suspended",user="bob@example.com"} 1
+tailscale_inventory_orphaned_device{id="dev-4",name="old-server",reason="unknown",user="eve@example.com"} 1
+# HELP tailscale_inventory_orphaned_devices Number of devices owned by a suspended or unknown user.
+# TYPE tailscale_inventory_orphaned_devices gauge
+tailscale_inventory_orphaned_devices{reason="suspended"} 1
+tailscale_inventory_orphaned_devices{reason="unknown"} 1
+# HELP tailscale_inventory_role_devices Number of devices owned by users with the role.
+# TYPE tailscale_inventory_role_devices gauge
+tailscale_inventory_role_devices{role="admin"} 2
+tailscale_inventory_role_devices{role="member"} 1
+# HELP tailscale_inventory_tagged_devices Number of devices owned by tags.
+# TYPE tailscale_inventory_tagged_devices gauge
+tailscale_inventory_tagged_devices 1
+# HELP tailscale_inventory_user_devices Number of devices owned by the user, devices owned by tags are not attributed to a user.
+# TYPE tailscale_inventory_user_devices gauge
+tailscale_inventory_user_devices{login_name="alice@example.com",role="admin",status="active",user_id="user-1"} 2
+tailscale_inventory_user_devices{login_name="bob@example.com",role="member",status="suspended",user_id="user-2"} 1
+tailscale_inventory_user_devices{login_name="carol@example.com",role="member",status="idle",user_id="user-3"} 0
+tailscale_inventory_user_devices{login_name="dave@partner.com",role="member",status="active",user_id="user-4"} 0
+# HELP tailscale_inventory_user_without_devices Member user that owns no devices in the tailnet.
+# TYPE tailscale_inventory_user_without_devices gauge
+tailscale_inventory_user_without_devices{login_name="carol@example.com",role="member",status="idle",user_id="user-3"} 1
+`,
+		},
+		{
+			name: "users error",
+			mockClient: &MockTailscaleClient{
+				usersClient:   &MockUsersClient{usersErr: tailscale.APIError{Status: http.StatusInternalServerError}},
+				devicesClient: &MockDevicesClient{},
+			},
+			expectError: true,
+		},
+		{
+			name: "devices error",
+			mockClient: &MockTailscaleClient{
+				usersClient:   &MockUsersClient{},
+				devicesClient: &MockDevicesClient{devicesErr: tailscale.APIError{Status: http.StatusInternalServerError}},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &TailscaleInventoryCollector{
+				log: logger,
+			}
+
+			ch := make(chan prometheus.Metric, 64)
+			err := collector.Update(context.Background(), tt.mockClient, ch)
+			close(ch)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			var metrics []prometheus.Metric
+			for metric := range ch {
+				metrics = append(metrics, metric)
+			}
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&TestMetricCollector{metrics: metrics})
+
+			if err := testutil.GatherAndCompare(
+				reg,
+				strings.NewReader(tt.expectedMetrics),
+			); err != nil {
+				t.Errorf("metrics mismatch: %v", err)
+			}
+		})
+	}
+}
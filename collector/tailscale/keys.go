@@ -3,12 +3,20 @@ package tailscale
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
 )
 
 const keysSubsystem = "keys"
 
+// defaultKeysLongLivedThreshold is the lifetime above which reusable auth keys
+// are counted as long-lived.
+const defaultKeysLongLivedThreshold = 30 * 24 * time.Hour
+
 var (
 	keysInfoDesc = newDesc(
 		keysSubsystem,
@@ -30,10 +38,74 @@ var (
 		"Timestamp when the key expires.",
 		[]string{"id", "key_type", "user_id"},
 	)
+
+	keysDetailsDesc = newDesc(
+		keysSubsystem,
+		"details_info",
+		"Key description and tags, tags are the tags applied to devices created with an auth key.",
+		[]string{"id", "key_type", "user_id", "description", "tags"},
+	)
+
+	keysReusableDesc = newDesc(
+		keysSubsystem,
+		"reusable",
+		"Whether the auth key can be used to register multiple devices (1 = reusable, 0 = single use).",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysEphemeralDesc = newDesc(
+		keysSubsystem,
+		"ephemeral",
+		"Whether devices registered with the auth key are ephemeral (1 = ephemeral, 0 = not ephemeral).",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysPreauthorizedDesc = newDesc(
+		keysSubsystem,
+		"preauthorized",
+		"Whether devices registered with the auth key are preauthorized (1 = preauthorized, 0 = not preauthorized).",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysRevokedDesc = newDesc(
+		keysSubsystem,
+		"revoked",
+		"Whether the key has been revoked (1 = revoked, 0 = not revoked).",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysRevokedTimestampDesc = newDesc(
+		keysSubsystem,
+		"revoked_timestamp",
+		"Timestamp when the key was revoked, only reported for revoked keys.",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysInvalidDesc = newDesc(
+		keysSubsystem,
+		"invalid",
+		"Whether the key is invalid (1 = invalid, 0 = valid).",
+		[]string{"id", "key_type", "user_id"},
+	)
+
+	keysReusableLongLivedDesc = newDesc(
+		keysSubsystem,
+		"reusable_long_lived_count",
+		"Number of valid reusable auth keys with a lifetime above the long-lived threshold.",
+		nil,
+	)
+
+	keysTaggedDesc = newDesc(
+		keysSubsystem,
+		"tagged_count",
+		"Number of valid keys with tags.",
+		nil,
+	)
 )
 
 type TailscaleKeysCollector struct {
-	log *slog.Logger
+	log                *slog.Logger
+	longLivedThreshold time.Duration
 }
 
 func init() {
@@ -41,8 +113,14 @@ func init() {
 }
 
 func NewTailscaleKeysCollector(config collectorConfig) (Collector, error) {
+	threshold := config.KeysLongLivedThreshold
+	if threshold <= 0 {
+		threshold = defaultKeysLongLivedThreshold
+	}
+
 	return &TailscaleKeysCollector{
-		log: config.logger,
+		log:                config.logger,
+		longLivedThreshold: threshold,
 	}, nil
 }
 
@@ -59,7 +137,23 @@ func (c TailscaleKeysCollector) Update(
 		return err
 	}
 
+	var reusableLongLived, tagged float64
 	for _, key := range keys {
+		create := key.Capabilities.Devices.Create
+		tags := keyTags(key)
+		revoked := !key.Revoked.IsZero()
+
+		if !revoked && !key.Invalid {
+			// Auth keys always expire, a key without expiry is counted
+			// as well in case the API omits it.
+			if create.Reusable && (key.Expires.IsZero() || key.Expires.Sub(key.Created) > c.longLivedThreshold) {
+				reusableLongLived++
+			}
+			if len(tags) > 0 {
+				tagged++
+			}
+		}
+
 		ch <- prometheus.MustNewConstMetric(
 			keysInfoDesc, prometheus.GaugeValue, 1,
 			key.ID, key.KeyType, key.UserID,
@@ -74,7 +168,64 @@ func (c TailscaleKeysCollector) Update(
 			keysExpiresDesc, prometheus.GaugeValue, float64(key.Expires.Unix()),
 			key.ID, key.KeyType, key.UserID,
 		)
+
+		ch <- prometheus.MustNewConstMetric(
+			keysDetailsDesc, prometheus.GaugeValue, 1,
+			key.ID, key.KeyType, key.UserID, key.Description, strings.Join(tags, ","),
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			keysReusableDesc, prometheus.GaugeValue, boolAsFloat(create.Reusable),
+			key.ID, key.KeyType, key.UserID,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			keysEphemeralDesc, prometheus.GaugeValue, boolAsFloat(create.Ephemeral),
+			key.ID, key.KeyType, key.UserID,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			keysPreauthorizedDesc, prometheus.GaugeValue, boolAsFloat(create.Preauthorized),
+			key.ID, key.KeyType, key.UserID,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			keysRevokedDesc, prometheus.GaugeValue, boolAsFloat(revoked),
+			key.ID, key.KeyType, key.UserID,
+		)
+
+		if revoked {
+			ch <- prometheus.MustNewConstMetric(
+				keysRevokedTimestampDesc, prometheus.GaugeValue, float64(key.Revoked.Unix()),
+				key.ID, key.KeyType, key.UserID,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			keysInvalidDesc, prometheus.GaugeValue, boolAsFloat(key.Invalid),
+			key.ID, key.KeyType, key.UserID,
+		)
 	}
 
+	ch <- prometheus.MustNewConstMetric(
+		keysReusableLongLivedDesc, prometheus.GaugeValue, reusableLongLived,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		keysTaggedDesc, prometheus.GaugeValue, tagged,
+	)
+
 	return nil
 }
+
+// keyTags returns the sorted tags of a key. Auth keys carry their tags in the
+// device creation capabilities, OAuth clients and federated identities at the
+// top level.
+func keyTags(key tailscale.Key) []string {
+	tags := slices.Clone(key.Capabilities.Devices.Create.Tags)
+	if len(tags) == 0 {
+		tags = slices.Clone(key.Tags)
+	}
+	slices.Sort(tags)
+	return tags
+}
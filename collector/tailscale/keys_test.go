@@ -15,9 +15,23 @@ import (
 func TestTailscaleKeysCollector_Update(t *testing.T) {
 	logger := slog.Default()
 
+	reusableKey := tailscale.Key{
+		ID:          "key-ci",
+		KeyType:     "auth",
+		UserID:      "user-456",
+		Description: "ci runners",
+		Created:     time.Unix(1700000000, 0),
+		Expires:     time.Unix(1700000000, 0).Add(90 * 24 * time.Hour),
+	}
+	reusableKey.Capabilities.Devices.Create.Reusable = true
+	reusableKey.Capabilities.Devices.Create.Ephemeral = true
+	reusableKey.Capabilities.Devices.Create.Preauthorized = true
+	reusableKey.Capabilities.Devices.Create.Tags = []string{"tag:runner", "tag:ci"}
+
 	tests := []struct {
 		name            string
 		mockClient      *MockTailscaleClient
+		metricNames     []string
 		expectedMetrics string
 		expectError     bool
 	}{
@@ -46,18 +60,115 @@ tailscale_keys_created_timestamp{id="key-123",key_type="auth",user_id="user-456"
 # HELP tailscale_keys_expires_timestamp Timestamp when the key expires.
 # TYPE tailscale_keys_expires_timestamp gauge
 tailscale_keys_expires_timestamp{id="key-123",key_type="auth",user_id="user-456"} -62135596800
+# HELP tailscale_keys_reusable Whether the auth key can be used to register multiple devices (1 = reusable, 0 = single use).
+# TYPE tailscale_keys_reusable gauge
+tailscale_keys_reusable{id="key-123",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_ephemeral Whether devices registered with the auth key are ephemeral (1 = ephemeral, 0 = not ephemeral).
+# TYPE tailscale_keys_ephemeral gauge
+tailscale_keys_ephemeral{id="key-123",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_preauthorized Whether devices registered with the auth key are preauthorized (1 = preauthorized, 0 = not preauthorized).
+# TYPE tailscale_keys_preauthorized gauge
+tailscale_keys_preauthorized{id="key-123",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_revoked Whether the key has been revoked (1 = revoked, 0 = not revoked).
+# TYPE tailscale_keys_revoked gauge
+tailscale_keys_revoked{id="key-123",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_invalid Whether the key is invalid (1 = invalid, 0 = valid).
+# TYPE tailscale_keys_invalid gauge
+tailscale_keys_invalid{id="key-123",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_details_info Key description and tags, tags are the tags applied to devices created with an auth key.
+# TYPE tailscale_keys_details_info gauge
+tailscale_keys_details_info{description="",id="key-123",key_type="auth",tags="",user_id="user-456"} 1
+# HELP tailscale_keys_reusable_long_lived_count Number of valid reusable auth keys with a lifetime above the long-lived threshold.
+# TYPE tailscale_keys_reusable_long_lived_count gauge
+tailscale_keys_reusable_long_lived_count 0
+# HELP tailscale_keys_tagged_count Number of valid keys with tags.
+# TYPE tailscale_keys_tagged_count gauge
+tailscale_keys_tagged_count 0
 `,
 			expectError: false,
 		},
+		{
+			name: "auth key capabilities and fleet counts",
+			mockClient: &MockTailscaleClient{
+				keysClient: &MockKeysClient{
+					keys: []tailscale.Key{
+						reusableKey,
+						{
+							ID:          "key-revoked",
+							KeyType:     "auth",
+							UserID:      "user-456",
+							Description: "old ci",
+							Created:     time.Unix(1700000000, 0),
+							Revoked:     time.Unix(1700000100, 0),
+						},
+						{
+							ID:          "client-1",
+							KeyType:     "client",
+							UserID:      "user-456",
+							Description: "operator",
+							Created:     time.Unix(1700000000, 0),
+							Tags:        []string{"tag:k8s-operator"},
+						},
+					},
+				},
+			},
+			metricNames: []string{
+				"tailscale_keys_details_info",
+				"tailscale_keys_reusable",
+				"tailscale_keys_ephemeral",
+				"tailscale_keys_preauthorized",
+				"tailscale_keys_revoked",
+				"tailscale_keys_revoked_timestamp",
+				"tailscale_keys_reusable_long_lived_count",
+				"tailscale_keys_tagged_count",
+			},
+			expectedMetrics: `
+# HELP tailscale_keys_details_info Key description and tags, tags are the tags applied to devices created with an auth key.
+# TYPE tailscale_keys_details_info gauge
+tailscale_keys_details_info{description="ci runners",id="key-ci",key_type="auth",tags="tag:ci,tag:runner",user_id="user-456"} 1
+tailscale_keys_details_info{description="old ci",id="key-revoked",key_type="auth",tags="",user_id="user-456"} 1
+tailscale_keys_details_info{description="operator",id="client-1",key_type="client",tags="tag:k8s-operator",user_id="user-456"} 1
+# HELP tailscale_keys_reusable Whether the auth key can be used to register multiple devices (1 = reusable, 0 = single use).
+# TYPE tailscale_keys_reusable gauge
+tailscale_keys_reusable{id="client-1",key_type="client",user_id="user-456"} 0
+tailscale_keys_reusable{id="key-ci",key_type="auth",user_id="user-456"} 1
+tailscale_keys_reusable{id="key-revoked",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_ephemeral Whether devices registered with the auth key are ephemeral (1 = ephemeral, 0 = not ephemeral).
+# TYPE tailscale_keys_ephemeral gauge
+tailscale_keys_ephemeral{id="client-1",key_type="client",user_id="user-456"} 0
+tailscale_keys_ephemeral{id="key-ci",key_type="auth",user_id="user-456"} 1
+tailscale_keys_ephemeral{id="key-revoked",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_preauthorized Whether devices registered with the auth key are preauthorized (1 = preauthorized, 0 = not preauthorized).
+# TYPE tailscale_keys_preauthorized gauge
+tailscale_keys_preauthorized{id="client-1",key_type="client",user_id="user-456"} 0
+tailscale_keys_preauthorized{id="key-ci",key_type="auth",user_id="user-456"} 1
+tailscale_keys_preauthorized{id="key-revoked",key_type="auth",user_id="user-456"} 0
+# HELP tailscale_keys_revoked Whether the key has been revoked (1 = revoked, 0 = not revoked).
+# TYPE tailscale_keys_revoked gauge
+tailscale_keys_revoked{id="client-1",key_type="client",user_id="user-456"} 0
+tailscale_keys_revoked{id="key-ci",key_type="auth",user_id="user-456"} 0
+tailscale_keys_revoked{id="key-revoked",key_type="auth",user_id="user-456"} 1
+# HELP tailscale_keys_revoked_timestamp Timestamp when the key was revoked, only reported for revoked keys.
+# TYPE tailscale_keys_revoked_timestamp gauge
+tailscale_keys_revoked_timestamp{id="key-revoked",key_type="auth",user_id="user-456"} 1.7000001e+09
+# HELP tailscale_keys_reusable_long_lived_count Number of valid reusable auth keys with a lifetime above the long-lived threshold.
+# TYPE tailscale_keys_reusable_long_lived_count gauge
+tailscale_keys_reusable_long_lived_count 1
+# HELP tailscale_keys_tagged_count Number of valid keys with tags.
+# TYPE tailscale_keys_tagged_count gauge
+tailscale_keys_tagged_count 2
+`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			collector := &TailscaleKeysCollector{
-				log: logger,
+				log:                logger,
+				longLivedThreshold: defaultKeysLongLivedThreshold,
 			}
 
-			ch := make(chan prometheus.Metric, 10)
+			ch := make(chan prometheus.Metric, 64)
 			ctx := context.Background()
 
 			err := collector.Update(ctx, tt.mockClient, ch)
@@ -92,6 +203,77 @@ tailscale_keys_expires_timestamp{id="key-123",key_type="auth",user_id="user-456"
 			if err := testutil.GatherAndCompare(
 				reg,
 				strings.NewReader(tt.expectedMetrics),
+				tt.metricNames...,
+			); err != nil {
+				t.Errorf("metrics mismatch: %v", err)
+			}
+		})
+	}
+}
+
+func TestTailscaleKeysCollector_LongLived(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	reusableKey := func(id string, lifetime time.Duration) tailscale.Key {
+		key := tailscale.Key{
+			ID:      id,
+			KeyType: "auth",
+			Created: created,
+			Expires: created.Add(lifetime),
+		}
+		key.Capabilities.Devices.Create.Reusable = true
+		return key
+	}
+	client := &MockTailscaleClient{
+		keysClient: &MockKeysClient{
+			keys: []tailscale.Key{
+				reusableKey("key-week", 7*24*time.Hour),
+				reusableKey("key-60d", 60*24*time.Hour),
+				reusableKey("key-90d", 90*24*time.Hour),
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		expected  string
+	}{
+		{name: "default threshold", expected: "2"},
+		{name: "configured threshold", threshold: 60 * 24 * time.Hour, expected: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := NewTailscaleKeysCollector(collectorConfig{
+				logger: slog.Default(),
+				Config: Config{KeysLongLivedThreshold: tt.threshold},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ch := make(chan prometheus.Metric, 64)
+			if err := collector.Update(context.Background(), client, ch); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			close(ch)
+
+			var metrics []prometheus.Metric
+			for metric := range ch {
+				metrics = append(metrics, metric)
+			}
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&TestMetricCollector{metrics: metrics})
+
+			expected := `
+# HELP tailscale_keys_reusable_long_lived_count Number of valid reusable auth keys with a lifetime above the long-lived threshold.
+# TYPE tailscale_keys_reusable_long_lived_count gauge
+tailscale_keys_reusable_long_lived_count ` + tt.expected + `
+`
+			if err := testutil.GatherAndCompare(
+				reg,
+				strings.NewReader(expected),
+				"tailscale_keys_reusable_long_lived_count",
 			); err != nil {
 				t.Errorf("metrics mismatch: %v", err)
 			}
@@ -0,0 +1,110 @@
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/lifecycle"
+)
+
+const lifecycleSubsystem = "lifecycle"
+
+var (
+	lifecycleAddedDesc = newDesc(
+		"",
+		"devices_added_total",
+		"Number of devices that joined the tailnet.",
+		nil,
+	)
+	lifecycleRemovedDesc = newDesc(
+		"",
+		"devices_removed_total",
+		"Number of devices that left the tailnet.",
+		nil,
+	)
+	lifecycleOnlineTransitionsDesc = newDesc(
+		"",
+		"device_online_transitions_total",
+		"Number of times devices connected to or disconnected from the control plane.",
+		[]string{"direction"},
+	)
+	lifecycleAuthorizationChangesDesc = newDesc(
+		"",
+		"device_authorization_changes_total",
+		"Number of times devices were authorized or deauthorized.",
+		[]string{"direction"},
+	)
+)
+
+// TailscaleLifecycleCollector counts device changes between scrapes.
+type TailscaleLifecycleCollector struct {
+	log     *slog.Logger
+	tracker *lifecycle.Tracker
+}
+
+func init() {
+	registerCollector(lifecycleSubsystem, NewTailscaleLifecycleCollector)
+}
+
+func NewTailscaleLifecycleCollector(config collectorConfig) (Collector, error) {
+	tracker, err := lifecycle.NewTracker(config.LifecycleStateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lifecycle state: %w", err)
+	}
+
+	return &TailscaleLifecycleCollector{
+		log:     config.logger,
+		tracker: tracker,
+	}, nil
+}
+
+func (c TailscaleLifecycleCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting lifecycle metrics")
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error getting Tailscale devices", "error", err.Error())
+		return err
+	}
+
+	current := make(map[string]lifecycle.State, len(devices))
+	for _, device := range devices {
+		current[device.ID] = lifecycle.State{
+			Online:     device.ConnectedToControl,
+			Authorized: device.Authorized,
+		}
+	}
+
+	counts, err := c.tracker.Observe(current)
+	if err != nil {
+		c.log.ErrorContext(ctx, "Error saving lifecycle state", "error", err.Error())
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleAddedDesc, prometheus.CounterValue, counts.Added,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleRemovedDesc, prometheus.CounterValue, counts.Removed,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleOnlineTransitionsDesc, prometheus.CounterValue, counts.WentOnline, "online",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleOnlineTransitionsDesc, prometheus.CounterValue, counts.WentOffline, "offline",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleAuthorizationChangesDesc, prometheus.CounterValue, counts.Authorized, "authorized",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		lifecycleAuthorizationChangesDesc, prometheus.CounterValue, counts.Deauthorized, "deauthorized",
+	)
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleLifecycleCollector_Update(t *testing.T) {
+	collector, err := NewTailscaleLifecycleCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("failed to create lifecycle collector: %v", err)
+	}
+
+	devices := &MockDevicesClient{}
+	client := &MockTailscaleClient{devicesClient: devices}
+
+	scrapes := [][]tailscale.Device{
+		{
+			{ID: "dev-1", ConnectedToControl: true, Authorized: true},
+			{ID: "dev-2", ConnectedToControl: true, Authorized: true},
+		},
+		{
+			{ID: "dev-1", ConnectedToControl: false, Authorized: true},
+			{ID: "dev-3", ConnectedToControl: true, Authorized: false},
+		},
+		{
+			{ID: "dev-1", ConnectedToControl: true, Authorized: true},
+			{ID: "dev-3", ConnectedToControl: true, Authorized: true},
+		},
+	}
+
+	var metrics []prometheus.Metric
+	for _, scrape := range scrapes {
+		devices.devices = scrape
+		ch := make(chan prometheus.Metric, 16)
+		if err := collector.Update(context.Background(), client, ch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		close(ch)
+
+		metrics = metrics[:0]
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	expected := `
+# HELP tailscale_device_authorization_changes_total Number of times devices were authorized or deauthorized.
+# TYPE tailscale_device_authorization_changes_total counter
+tailscale_device_authorization_changes_total{direction="authorized"} 1
+tailscale_device_authorization_changes_total{direction="deauthorized"} 0
+# HELP tailscale_device_online_transitions_total Number of times devices connected to or disconnected from the control plane.
+# TYPE tailscale_device_online_transitions_total counter
+tailscale_device_online_transitions_total{direction="offline"} 1
+tailscale_device_online_transitions_total{direction="online"} 1
+# HELP tailscale_devices_added_total Number of devices that joined the tailnet.
+# TYPE tailscale_devices_added_total counter
+tailscale_devices_added_total 1
+# HELP tailscale_devices_removed_total Number of devices that left the tailnet.
+# TYPE tailscale_devices_removed_total counter
+tailscale_devices_removed_total 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tailscale.com/client/tailscale/v2"
+)
+
+// loggingResource extends *tailscale.LoggingResource with the logging
+// endpoints that the client library does not cover.
+type loggingResource struct {
+	*tailscale.LoggingResource
+}
+
+func (r loggingResource) LogstreamStatus(
+	ctx context.Context,
+	logType tailscale.LogType,
+) (*LogstreamStatus, error) {
+	var status LogstreamStatus
+	if err := r.get(ctx, &status, "logging", string(logType), "stream", "status"); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ConfigurationLogs lists the configuration audit logs recorded between start
+// and end.
+func (r loggingResource) ConfigurationLogs(
+	ctx context.Context,
+	start, end time.Time,
+) ([]ConfigurationLog, error) {
+	var resp struct {
+		Logs []ConfigurationLog `json:"logs"`
+	}
+	query := url.Values{
+		"start": {start.UTC().Format(time.RFC3339Nano)},
+		"end":   {end.UTC().Format(time.RFC3339Nano)},
+	}
+	if err := r.getWithQuery(ctx, &resp, query, "logging", "configuration"); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// readLogWindows calls read for consecutive windows of at most window between
+// the cursor and now, advancing the cursor after every window that was read.
+// The cursor is never moved further back than retention.
+func readLogWindows(
+	cursor *time.Time,
+	now time.Time,
+	window, retention time.Duration,
+	read func(start, end time.Time) error,
+) error {
+	if oldest := now.Add(-retention); cursor.Before(oldest) {
+		*cursor = oldest
+	}
+
+	for cursor.Before(now) {
+		end := cursor.Add(window)
+		if end.After(now) {
+			end = now
+		}
+		if err := read(*cursor, end); err != nil {
+			return err
+		}
+		*cursor = end
+	}
+
+	return nil
+}
+
+// get performs a GET request against /api/v2/tailnet/{tailnet}/{path...} and
+// decodes the JSON response into out. Error responses are returned as
+// tailscale.APIError so that helpers such as tailscale.IsNotFound work.
+func (r loggingResource) get(
+	ctx context.Context,
+	out any,
+	path ...string,
+) error {
+	return r.getWithQuery(ctx, out, nil, path...)
+}
+
+func (r loggingResource) getWithQuery(
+	ctx context.Context,
+	out any,
+	query url.Values,
+	path ...string,
+) error {
+	elems := []string{"api", "v2", "tailnet", url.PathEscape(r.Tailnet)}
+	for _, p := range path {
+		elems = append(elems, url.PathEscape(p))
+	}
+	u := r.BaseURL.JoinPath(elems...)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.APIKey != "" {
+		req.SetBasicAuth(r.APIKey, "")
+	}
+
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := tailscale.APIError{Status: resp.StatusCode}
+		if err := json.Unmarshal(body, &apiErr); err != nil {
+			return fmt.Errorf("GET %s: HTTP %d", u.Path, resp.StatusCode)
+		}
+		apiErr.Status = resp.StatusCode
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,62 @@
+package tailscale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestLoggingResource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/tailnet/example.com/logging/network/stream/status":
+			_, _ = w.Write([]byte(`{"lastActivity":"2023-11-14T22:13:20Z","lastError":"","numBytesSent":42}`))
+		case "/api/v2/tailnet/example.com/logging/configuration":
+			if r.URL.Query().Get("start") != "2023-11-14T22:13:20Z" {
+				t.Errorf("unexpected start: %q", r.URL.Query().Get("start"))
+			}
+			_, _ = w.Write([]byte(`{"version":"1.1","logs":[{"action":"UPDATE","actor":{"type":"USER"},"target":{"type":"POLICY"},"eventTime":"2023-11-14T22:14:00Z"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &tailscale.Client{BaseURL: baseURL, Tailnet: "example.com", HTTP: server.Client()}
+	logging := NewTailscaleClientWrapper(client).Logging()
+
+	status, err := logging.LogstreamStatus(context.Background(), tailscale.LogTypeNetwork)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.NumBytesSent != 42 || status.LastActivity.Unix() != 1_700_000_000 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	_, err = logging.LogstreamStatus(context.Background(), tailscale.LogTypeConfig)
+	if !tailscale.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	logs, err := logging.ConfigurationLogs(
+		context.Background(),
+		time.Unix(1_700_000_000, 0),
+		time.Unix(1_700_000_100, 0),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Action != "UPDATE" || logs[0].Target.Type != "POLICY" {
+		t.Errorf("unexpected logs: %+v", logs)
+	}
+}
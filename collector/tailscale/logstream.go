@@ -0,0 +1,196 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
+)
+
+const logStreamingSubsystem = "log_streaming"
+
+var logStreamingLogTypes = []tailscale.LogType{
+	tailscale.LogTypeConfig,
+	tailscale.LogTypeNetwork,
+}
+
+var (
+	logStreamingEnabledDesc = newDesc(
+		logStreamingSubsystem,
+		"enabled",
+		"Whether log streaming is configured for the log type.",
+		[]string{"log_type"},
+	)
+	logStreamingInfoDesc = newDesc(
+		logStreamingSubsystem,
+		"info",
+		"Log streaming configuration for the log type.",
+		[]string{"log_type", "destination_type", "compression_format"},
+	)
+	logStreamingUploadPeriodDesc = newDesc(
+		logStreamingSubsystem,
+		"upload_period_minutes",
+		"Upload period of the log stream in minutes.",
+		[]string{"log_type"},
+	)
+	logStreamingErrorDesc = newDesc(
+		logStreamingSubsystem,
+		"error",
+		"Whether the log stream reports an error for its last delivery attempt.",
+		[]string{"log_type"},
+	)
+	logStreamingLastActivityDesc = newDesc(
+		logStreamingSubsystem,
+		"last_activity_timestamp",
+		"Unix timestamp of the last log stream delivery.",
+		[]string{"log_type"},
+	)
+	logStreamingSentBytesDesc = newDesc(
+		logStreamingSubsystem,
+		"sent_bytes_total",
+		"Number of bytes sent by the log stream.",
+		[]string{"log_type"},
+	)
+	logStreamingSentEntriesDesc = newDesc(
+		logStreamingSubsystem,
+		"sent_entries_total",
+		"Number of log entries sent by the log stream.",
+		[]string{"log_type"},
+	)
+	logStreamingRequestsDesc = newDesc(
+		logStreamingSubsystem,
+		"requests_total",
+		"Number of requests made by the log stream.",
+		[]string{"log_type"},
+	)
+	logStreamingFailedRequestsDesc = newDesc(
+		logStreamingSubsystem,
+		"failed_requests_total",
+		"Number of failed requests made by the log stream.",
+		[]string{"log_type"},
+	)
+)
+
+// LogstreamStatus is the delivery status of a log stream as reported by
+// /api/v2/tailnet/{tailnet}/logging/{logType}/stream/status.
+type LogstreamStatus struct {
+	LastActivity      time.Time `json:"lastActivity"`
+	LastError         string    `json:"lastError"`
+	NumBytesSent      uint64    `json:"numBytesSent"`
+	NumEntriesSent    uint64    `json:"numEntriesSent"`
+	NumFailedRequests uint64    `json:"numFailedRequests"`
+	NumTotalRequests  uint64    `json:"numTotalRequests"`
+}
+
+type TailscaleLogStreamingCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(logStreamingSubsystem, NewTailscaleLogStreamingCollector)
+}
+
+// NewTailscaleLogStreamingCollector creates the log streaming collector. It is
+// only enabled when configured, as it requires an additional OAuth scope.
+func NewTailscaleLogStreamingCollector(config collectorConfig) (Collector, error) {
+	if !config.LogStreaming {
+		return nil, nil
+	}
+
+	return &TailscaleLogStreamingCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c TailscaleLogStreamingCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting log streaming metrics")
+
+	for _, logType := range logStreamingLogTypes {
+		if err := c.updateLogType(ctx, client, logType, ch); err != nil {
+			c.log.ErrorContext(
+				ctx,
+				"Error getting Tailscale log streaming; ensure the OAuth client has log_streaming:read",
+				"log_type",
+				logType,
+				"error",
+				err.Error(),
+			)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c TailscaleLogStreamingCollector) updateLogType(
+	ctx context.Context,
+	client TailscaleClient,
+	logType tailscale.LogType,
+	ch chan<- prometheus.Metric,
+) error {
+	config, err := client.Logging().LogstreamConfiguration(ctx, logType)
+	if tailscale.IsNotFound(err) {
+		config, err = &tailscale.LogstreamConfiguration{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	enabled := config.DestinationType != ""
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingEnabledDesc, prometheus.GaugeValue, boolAsFloat(enabled),
+		string(logType),
+	)
+	if !enabled {
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingInfoDesc, prometheus.GaugeValue, 1,
+		string(logType), string(config.DestinationType), string(config.CompressionFormat),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingUploadPeriodDesc, prometheus.GaugeValue, float64(config.UploadPeriodMinutes),
+		string(logType),
+	)
+
+	status, err := client.Logging().LogstreamStatus(ctx, logType)
+	if err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingErrorDesc, prometheus.GaugeValue, boolAsFloat(status.LastError != ""),
+		string(logType),
+	)
+	if !status.LastActivity.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			logStreamingLastActivityDesc, prometheus.GaugeValue, float64(status.LastActivity.Unix()),
+			string(logType),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingSentBytesDesc, prometheus.CounterValue, float64(status.NumBytesSent),
+		string(logType),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingSentEntriesDesc, prometheus.CounterValue, float64(status.NumEntriesSent),
+		string(logType),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingRequestsDesc, prometheus.CounterValue, float64(status.NumTotalRequests),
+		string(logType),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		logStreamingFailedRequestsDesc, prometheus.CounterValue, float64(status.NumFailedRequests),
+		string(logType),
+	)
+
+	return nil
+}
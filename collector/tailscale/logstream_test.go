@@ -0,0 +1,96 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleLogStreamingCollector_Update(t *testing.T) {
+	collector := &TailscaleLogStreamingCollector{log: slog.Default()}
+	ch := make(chan prometheus.Metric, 16)
+
+	err := collector.Update(context.Background(), &MockTailscaleClient{
+		loggingClient: &MockLoggingClient{
+			configurations: map[tailscale.LogType]*tailscale.LogstreamConfiguration{
+				tailscale.LogTypeConfig: {
+					LogType:             tailscale.LogTypeConfig,
+					DestinationType:     tailscale.LogstreamSplunkEndpoint,
+					CompressionFormat:   tailscale.CompressionFormatZstd,
+					UploadPeriodMinutes: 5,
+				},
+			},
+			statuses: map[tailscale.LogType]*LogstreamStatus{
+				tailscale.LogTypeConfig: {
+					LastActivity:      time.Unix(1_700_000_000, 0),
+					LastError:         "connection refused",
+					NumBytesSent:      2048,
+					NumEntriesSent:    10,
+					NumFailedRequests: 1,
+					NumTotalRequests:  3,
+				},
+			},
+		},
+	}, ch)
+	close(ch)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP tailscale_log_streaming_enabled Whether log streaming is configured for the log type.
+# TYPE tailscale_log_streaming_enabled gauge
+tailscale_log_streaming_enabled{log_type="configuration"} 1
+tailscale_log_streaming_enabled{log_type="network"} 0
+# HELP tailscale_log_streaming_error Whether the log stream reports an error for its last delivery attempt.
+# TYPE tailscale_log_streaming_error gauge
+tailscale_log_streaming_error{log_type="configuration"} 1
+# HELP tailscale_log_streaming_failed_requests_total Number of failed requests made by the log stream.
+# TYPE tailscale_log_streaming_failed_requests_total counter
+tailscale_log_streaming_failed_requests_total{log_type="configuration"} 1
+# HELP tailscale_log_streaming_info Log streaming configuration for the log type.
+# TYPE tailscale_log_streaming_info gauge
+tailscale_log_streaming_info{compression_format="zstd",destination_type="splunk",log_type="configuration"} 1
+# HELP tailscale_log_streaming_last_activity_timestamp Unix timestamp of the last log stream delivery.
+# TYPE tailscale_log_streaming_last_activity_timestamp gauge
+tailscale_log_streaming_last_activity_timestamp{log_type="configuration"} 1.7e+09
+# HELP tailscale_log_streaming_requests_total Number of requests made by the log stream.
+# TYPE tailscale_log_streaming_requests_total counter
+tailscale_log_streaming_requests_total{log_type="configuration"} 3
+# HELP tailscale_log_streaming_sent_bytes_total Number of bytes sent by the log stream.
+# TYPE tailscale_log_streaming_sent_bytes_total counter
+tailscale_log_streaming_sent_bytes_total{log_type="configuration"} 2048
+# HELP tailscale_log_streaming_sent_entries_total Number of log entries sent by the log stream.
+# TYPE tailscale_log_streaming_sent_entries_total counter
+tailscale_log_streaming_sent_entries_total{log_type="configuration"} 10
+# HELP tailscale_log_streaming_upload_period_minutes Upload period of the log stream in minutes.
+# TYPE tailscale_log_streaming_upload_period_minutes gauge
+tailscale_log_streaming_upload_period_minutes{log_type="configuration"} 5
+`)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
+
+func TestNewTailscaleLogStreamingCollector_Disabled(t *testing.T) {
+	coll, err := NewTailscaleLogStreamingCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coll != nil {
+		t.Errorf("expected log streaming collector to be disabled")
+	}
+}
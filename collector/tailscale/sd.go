@@ -0,0 +1,42 @@
+package tailscale
+
+import (
+	"context"
+	"strings"
+
+	"tailscale.com/client/tailscale/v2"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/iputil"
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+// ServiceDiscoveryDevices lists the devices of the tailnet as service
+// discovery targets.
+func (t *TailscaleCollector) ServiceDiscoveryDevices(ctx context.Context) ([]sd.Device, error) {
+	devices, err := t.client.Devices().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sdDevices(devices, t.tailnet), nil
+}
+
+func sdDevices(devices []tailscale.Device, tailnet string) []sd.Device {
+	result := make([]sd.Device, 0, len(devices))
+	for _, device := range devices {
+		// Device names are fully qualified MagicDNS names.
+		machine, _, _ := strings.Cut(device.Name, ".")
+		ipv4, ipv6 := iputil.SplitIPs(device.Addresses)
+		result = append(result, sd.Device{
+			Machine:  machine,
+			Hostname: device.Hostname,
+			OS:       device.OS,
+			User:     device.User,
+			Tags:     device.Tags,
+			Tailnet:  tailnet,
+			Online:   device.ConnectedToControl,
+			IPv4:     ipv4,
+			IPv6:     ipv6,
+		})
+	}
+	return result
+}
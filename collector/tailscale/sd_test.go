@@ -0,0 +1,41 @@
+package tailscale
+
+import (
+	"reflect"
+	"testing"
+
+	"tailscale.com/client/tailscale/v2"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/sd"
+)
+
+func TestSDDevices(t *testing.T) {
+	devices := []tailscale.Device{
+		{
+			Name:               "laptop.tail1234.ts.net",
+			Hostname:           "alice-laptop",
+			OS:                 "macOS",
+			User:               "alice@example.com",
+			Tags:               []string{"tag:dev"},
+			Addresses:          []string{"fd7a:115c:a1e0::1", "100.64.0.1"},
+			ConnectedToControl: true,
+		},
+	}
+
+	want := []sd.Device{
+		{
+			Machine:  "laptop",
+			Hostname: "alice-laptop",
+			OS:       "macOS",
+			User:     "alice@example.com",
+			Tags:     []string{"tag:dev"},
+			Tailnet:  "example.com",
+			Online:   true,
+			IPv4:     "100.64.0.1",
+			IPv6:     "fd7a:115c:a1e0::1",
+		},
+	}
+	if got := sdDevices(devices, "example.com"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
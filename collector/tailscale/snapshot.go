@@ -0,0 +1,198 @@
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"tailscale.com/client/tailscale/v2"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+var (
+	scrapeCacheHitsDesc = newDesc(
+		"scrape",
+		"cache_hits_total",
+		"tailscale_exporter: Number of API reads served from the per-scrape snapshot.",
+		[]string{"resource"},
+	)
+	scrapeCacheMissesDesc = newDesc(
+		"scrape",
+		"cache_misses_total",
+		"tailscale_exporter: Number of API reads that were fetched from the Tailscale API.",
+		[]string{"resource"},
+	)
+)
+
+// snapshotClient serves the read-only API resources of a TailscaleClient from
+// a per-scrape cache. Log reads depend on their time window and are passed
+// through.
+type snapshotClient struct {
+	client TailscaleClient
+	cache  *snapshot.Cache
+}
+
+func newSnapshotClient(client TailscaleClient, stats *snapshot.Stats) *snapshotClient {
+	return &snapshotClient{
+		client: client,
+		cache:  snapshot.New(stats),
+	}
+}
+
+func (s *snapshotClient) Contacts() ContactsAPI {
+	return snapshotContacts{s}
+}
+
+func (s *snapshotClient) Keys() KeysAPI {
+	return snapshotKeys{s}
+}
+
+func (s *snapshotClient) DNS() DNSAPI {
+	return snapshotDNS{s}
+}
+
+func (s *snapshotClient) Devices() DevicesAPI {
+	return snapshotDevices{s}
+}
+
+func (s *snapshotClient) Logging() LoggingAPI {
+	return s.client.Logging()
+}
+
+func (s *snapshotClient) Services() ServicesAPI {
+	return snapshotServices{s}
+}
+
+func (s *snapshotClient) Users() UsersAPI {
+	return snapshotUsers{s}
+}
+
+func (s *snapshotClient) TailnetSettings() TailnetSettingsAPI {
+	return snapshotTailnetSettings{s}
+}
+
+func (s *snapshotClient) Webhooks() WebhooksAPI {
+	return snapshotWebhooks{s}
+}
+
+type snapshotContacts struct{ s *snapshotClient }
+
+func (r snapshotContacts) Get(ctx context.Context) (*tailscale.Contacts, error) {
+	return snapshot.Fetch(r.s.cache, "contacts", func() (*tailscale.Contacts, error) {
+		return r.s.client.Contacts().Get(ctx)
+	})
+}
+
+type snapshotKeys struct{ s *snapshotClient }
+
+func (r snapshotKeys) List(ctx context.Context, all bool) ([]tailscale.Key, error) {
+	if !all {
+		return r.s.client.Keys().List(ctx, all)
+	}
+	return snapshot.Fetch(r.s.cache, "keys", func() ([]tailscale.Key, error) {
+		return r.s.client.Keys().List(ctx, all)
+	})
+}
+
+type snapshotDNS struct{ s *snapshotClient }
+
+func (r snapshotDNS) Nameservers(ctx context.Context) ([]string, error) {
+	return snapshot.Fetch(r.s.cache, "dns_nameservers", func() ([]string, error) {
+		return r.s.client.DNS().Nameservers(ctx)
+	})
+}
+
+func (r snapshotDNS) Preferences(ctx context.Context) (*tailscale.DNSPreferences, error) {
+	return snapshot.Fetch(r.s.cache, "dns_preferences", func() (*tailscale.DNSPreferences, error) {
+		return r.s.client.DNS().Preferences(ctx)
+	})
+}
+
+func (r snapshotDNS) Configuration(ctx context.Context) (*tailscale.DNSConfiguration, error) {
+	return snapshot.Fetch(r.s.cache, "dns_configuration", func() (*tailscale.DNSConfiguration, error) {
+		return r.s.client.DNS().Configuration(ctx)
+	})
+}
+
+type snapshotDevices struct{ s *snapshotClient }
+
+// List serves unfiltered listings from a single fetch that includes all device
+// fields, a superset of the fields any listing requests. Filtered listings are
+// passed through.
+func (r snapshotDevices) List(
+	ctx context.Context,
+	opts ...tailscale.ListDevicesOptions,
+) ([]tailscale.Device, error) {
+	query := listDevicesQuery(opts)
+	query.Del("fields")
+	if len(query) > 0 {
+		return r.s.client.Devices().List(ctx, opts...)
+	}
+	return snapshot.Fetch(r.s.cache, "devices", func() ([]tailscale.Device, error) {
+		return r.s.client.Devices().List(ctx, tailscale.WithFields(tailscale.IncludeFieldsAll))
+	})
+}
+
+var errQueryCaptured = errors.New("query captured")
+
+// listDevicesQuery returns the query parameters of a device listing with
+// opts. The options are opaque, so they are applied by a client whose request
+// is captured instead of sent.
+func listDevicesQuery(opts []tailscale.ListDevicesOptions) url.Values {
+	var query url.Values
+	client := &tailscale.Client{
+		HTTP: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			query = req.URL.Query()
+			return nil, errQueryCaptured
+		})},
+	}
+	_, _ = client.Devices().List(context.Background(), opts...)
+	return query
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type snapshotServices struct{ s *snapshotClient }
+
+func (r snapshotServices) List(ctx context.Context) ([]tailscale.Service, error) {
+	return snapshot.Fetch(r.s.cache, "services", func() ([]tailscale.Service, error) {
+		return r.s.client.Services().List(ctx)
+	})
+}
+
+type snapshotUsers struct{ s *snapshotClient }
+
+func (r snapshotUsers) List(
+	ctx context.Context,
+	userType *tailscale.UserType,
+	role *tailscale.UserRole,
+) ([]tailscale.User, error) {
+	if userType != nil || role != nil {
+		return r.s.client.Users().List(ctx, userType, role)
+	}
+	return snapshot.Fetch(r.s.cache, "users", func() ([]tailscale.User, error) {
+		return r.s.client.Users().List(ctx, nil, nil)
+	})
+}
+
+type snapshotTailnetSettings struct{ s *snapshotClient }
+
+func (r snapshotTailnetSettings) Get(ctx context.Context) (*tailscale.TailnetSettings, error) {
+	return snapshot.Fetch(r.s.cache, "tailnet_settings", func() (*tailscale.TailnetSettings, error) {
+		return r.s.client.TailnetSettings().Get(ctx)
+	})
+}
+
+type snapshotWebhooks struct{ s *snapshotClient }
+
+func (r snapshotWebhooks) List(ctx context.Context) ([]tailscale.Webhook, error) {
+	return snapshot.Fetch(r.s.cache, "webhooks", func() ([]tailscale.Webhook, error) {
+		return r.s.client.Webhooks().List(ctx)
+	})
+}
@@ -0,0 +1,125 @@
+package tailscale
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+
+	"github.com/adinhodovic/tailscale-exporter/collector/snapshot"
+)
+
+// countingDevicesClient counts the device listings that reach the API.
+type countingDevicesClient struct {
+	MockDevicesClient
+	calls atomic.Int32
+}
+
+func (m *countingDevicesClient) List(
+	ctx context.Context,
+	opts ...tailscale.ListDevicesOptions,
+) ([]tailscale.Device, error) {
+	m.calls.Add(1)
+	return m.MockDevicesClient.List(ctx, opts...)
+}
+
+type countingClient struct {
+	*MockTailscaleClient
+	devices *countingDevicesClient
+}
+
+func (c countingClient) Devices() DevicesAPI {
+	return c.devices
+}
+
+func TestSnapshotClient(t *testing.T) {
+	devices := &countingDevicesClient{
+		MockDevicesClient: MockDevicesClient{
+			devices: []tailscale.Device{{ID: "dev-1"}},
+		},
+	}
+	stats := snapshot.NewStats()
+	client := newSnapshotClient(countingClient{
+		MockTailscaleClient: &MockTailscaleClient{
+			usersClient: &MockUsersClient{
+				usersErr: tailscale.APIError{Status: http.StatusInternalServerError},
+			},
+		},
+		devices: devices,
+	}, stats)
+
+	ctx := context.Background()
+	wg := sync.WaitGroup{}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := client.Devices().List(ctx)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if len(got) != 1 || got[0].ID != "dev-1" {
+				t.Errorf("unexpected devices: %v", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := devices.calls.Load(); calls != 1 {
+		t.Errorf("expected devices to be fetched once, got %d", calls)
+	}
+
+	// Listings of all fields are served from the same fetch.
+	if _, err := client.Devices().List(ctx, tailscale.WithFields(tailscale.IncludeFieldsAll)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := devices.calls.Load(); calls != 1 {
+		t.Errorf("expected the all fields listing to be served from the snapshot, got %d calls", calls)
+	}
+
+	// Filtered listings bypass the snapshot.
+	if _, err := client.Devices().List(ctx, tailscale.WithFilter("tags", []string{"tag:ci"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := devices.calls.Load(); calls != 2 {
+		t.Errorf("expected filtered listing to reach the API, got %d calls", calls)
+	}
+
+	// Errors are part of the snapshot as well.
+	for range 2 {
+		if _, err := client.Users().List(ctx, nil, nil); err == nil {
+			t.Error("expected users error")
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	ch := make(chan prometheus.Metric, 8)
+	stats.Collect(ch, scrapeCacheHitsDesc, scrapeCacheMissesDesc)
+	close(ch)
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+
+	expected := `
+# HELP tailscale_scrape_cache_hits_total tailscale_exporter: Number of API reads served from the per-scrape snapshot.
+# TYPE tailscale_scrape_cache_hits_total counter
+tailscale_scrape_cache_hits_total{resource="devices"} 8
+tailscale_scrape_cache_hits_total{resource="users"} 1
+# HELP tailscale_scrape_cache_misses_total tailscale_exporter: Number of API reads that were fetched from the Tailscale API.
+# TYPE tailscale_scrape_cache_misses_total counter
+tailscale_scrape_cache_misses_total{resource="devices"} 1
+tailscale_scrape_cache_misses_total{resource="users"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
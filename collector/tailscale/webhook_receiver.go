@@ -0,0 +1,197 @@
+package tailscale
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// WebhookReceiverPath is where the exporter receives Tailscale webhooks.
+	WebhookReceiverPath = "/webhooks/tailscale"
+
+	webhookSignatureHeader = "Tailscale-Webhook-Signature"
+	// webhookSignatureTolerance bounds the age of a signature to prevent
+	// replays of captured requests.
+	webhookSignatureTolerance = 5 * time.Minute
+	webhookMaxBodyBytes       = 1 << 20
+)
+
+// Types of webhook events that change the devices of the tailnet.
+const (
+	WebhookEventNodeCreated  = "nodeCreated"
+	WebhookEventNodeDeleted  = "nodeDeleted"
+	WebhookEventNodeApproved = "nodeApproved"
+)
+
+const (
+	webhookRejectSignature = "signature"
+	webhookRejectPayload   = "payload"
+	webhookRejectMethod    = "method"
+)
+
+// WebhookEvent is a single event of a Tailscale webhook delivery.
+type WebhookEvent struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   int             `json:"version"`
+	Type      string          `json:"type"`
+	Tailnet   string          `json:"tailnet"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookReceiver verifies and counts events pushed by Tailscale webhooks.
+type WebhookReceiver struct {
+	// OnEvent, when set, is called once per event type of a verified
+	// delivery, e.g. to refresh state kept between scrapes.
+	OnEvent func(eventType string)
+
+	log    *slog.Logger
+	secret []byte
+	now    func() time.Time
+
+	events   *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+}
+
+// NewWebhookReceiver returns a receiver that accepts deliveries signed with
+// secret.
+func NewWebhookReceiver(logger *slog.Logger, secret string) *WebhookReceiver {
+	r := &WebhookReceiver{
+		log:    logger,
+		secret: []byte(secret),
+		now:    time.Now,
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook_receiver",
+			Name:      "events_total",
+			Help:      "Number of webhook events received.",
+		}, []string{"type", "tailnet"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook_receiver",
+			Name:      "rejected_requests_total",
+			Help:      "Number of webhook deliveries that were rejected.",
+		}, []string{"reason"}),
+	}
+	for _, reason := range []string{webhookRejectSignature, webhookRejectPayload, webhookRejectMethod} {
+		r.rejected.WithLabelValues(reason)
+	}
+	return r
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *WebhookReceiver) Describe(ch chan<- *prometheus.Desc) {
+	r.events.Describe(ch)
+	r.rejected.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (r *WebhookReceiver) Collect(ch chan<- prometheus.Metric) {
+	r.events.Collect(ch)
+	r.rejected.Collect(ch)
+}
+
+func (r *WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		r.rejected.WithLabelValues(webhookRejectMethod).Inc()
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, webhookMaxBodyBytes))
+	if err != nil {
+		r.rejected.WithLabelValues(webhookRejectPayload).Inc()
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	err = verifyWebhookSignature(req.Header.Get(webhookSignatureHeader), body, r.secret, r.now())
+	if err != nil {
+		r.rejected.WithLabelValues(webhookRejectSignature).Inc()
+		r.log.WarnContext(req.Context(), "Rejected Tailscale webhook", "error", err.Error())
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var events []WebhookEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		r.rejected.WithLabelValues(webhookRejectPayload).Inc()
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var eventTypes []string
+	for _, event := range events {
+		r.log.DebugContext(req.Context(), "Received Tailscale webhook event",
+			"type", event.Type, "tailnet", event.Tailnet, "message", event.Message)
+		r.events.WithLabelValues(event.Type, event.Tailnet).Inc()
+		if !slices.Contains(eventTypes, event.Type) {
+			eventTypes = append(eventTypes, event.Type)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if r.OnEvent != nil {
+		for _, eventType := range eventTypes {
+			r.OnEvent(eventType)
+		}
+	}
+}
+
+// verifyWebhookSignature checks a Tailscale-Webhook-Signature header of the
+// form "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+func verifyWebhookSignature(header string, body, secret []byte, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for part := range strings.SplitSeq(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("malformed signature header")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp: %w", err)
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > webhookSignatureTolerance || age < -webhookSignatureTolerance {
+		return fmt.Errorf("signature timestamp outside tolerance: %s", age)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return errors.New("signature mismatch")
+}
@@ -0,0 +1,126 @@
+package tailscale
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func signWebhook(secret, body string, ts time.Time) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "." + body))
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	receiver := NewWebhookReceiver(slog.Default(), "tskey-webhook-secret")
+	receiver.now = func() time.Time { return now }
+	var received []string
+	receiver.OnEvent = func(eventType string) {
+		received = append(received, eventType)
+	}
+
+	body := `[
+		{"timestamp":"2023-11-14T22:13:20Z","version":1,"type":"nodeCreated","tailnet":"example.com","message":"Node created"},
+		{"timestamp":"2023-11-14T22:13:20Z","version":1,"type":"nodeCreated","tailnet":"example.com","message":"Node created"},
+		{"timestamp":"2023-11-14T22:13:20Z","version":1,"type":"policyUpdate","tailnet":"example.com","message":"Policy updated"}
+	]`
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		signature  string
+		wantStatus int
+	}{
+		{
+			name:       "valid delivery",
+			method:     http.MethodPost,
+			body:       body,
+			signature:  signWebhook("tskey-webhook-secret", body, now),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong secret",
+			method:     http.MethodPost,
+			body:       body,
+			signature:  signWebhook("other-secret", body, now),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "replayed delivery",
+			method:     http.MethodPost,
+			body:       body,
+			signature:  signWebhook("tskey-webhook-secret", body, now.Add(-time.Hour)),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing signature",
+			method:     http.MethodPost,
+			body:       body,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid payload",
+			method:     http.MethodPost,
+			body:       `{"type":"nodeCreated"}`,
+			signature:  signWebhook("tskey-webhook-secret", `{"type":"nodeCreated"}`, now),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, WebhookReceiverPath, strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(webhookSignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+
+	expected := `
+# HELP tailscale_webhook_receiver_events_total Number of webhook events received.
+# TYPE tailscale_webhook_receiver_events_total counter
+tailscale_webhook_receiver_events_total{tailnet="example.com",type="nodeCreated"} 2
+tailscale_webhook_receiver_events_total{tailnet="example.com",type="policyUpdate"} 1
+# HELP tailscale_webhook_receiver_rejected_requests_total Number of webhook deliveries that were rejected.
+# TYPE tailscale_webhook_receiver_rejected_requests_total counter
+tailscale_webhook_receiver_rejected_requests_total{reason="method"} 1
+tailscale_webhook_receiver_rejected_requests_total{reason="payload"} 1
+tailscale_webhook_receiver_rejected_requests_total{reason="signature"} 3
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(receiver)
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+
+	// Only the verified delivery is passed on, once per event type.
+	if want := []string{WebhookEventNodeCreated, "policyUpdate"}; !slices.Equal(received, want) {
+		t.Errorf("expected events %v, got %v", want, received)
+	}
+}
@@ -0,0 +1,150 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"tailscale.com/client/tailscale/v2"
+)
+
+const webhooksSubsystem = "webhooks"
+
+// defaultWebhookProviderType is used for webhooks without a provider, which
+// receive the generic Tailscale payload.
+const defaultWebhookProviderType = "generic"
+
+var (
+	webhooksEndpointsDesc = newDesc(
+		webhooksSubsystem,
+		"endpoints",
+		"Number of configured webhook endpoints.",
+		nil,
+	)
+	webhooksInfoDesc = newDesc(
+		webhooksSubsystem,
+		"info",
+		"Webhook endpoint information. Only the host of the endpoint URL is exposed.",
+		[]string{"endpoint_id", "endpoint_host", "provider_type"},
+	)
+	webhooksSubscriptionDesc = newDesc(
+		webhooksSubsystem,
+		"subscription",
+		"Event or event category the webhook endpoint is subscribed to.",
+		[]string{"endpoint_id", "subscription"},
+	)
+	webhooksSubscriptionsDesc = newDesc(
+		webhooksSubsystem,
+		"subscriptions",
+		"Number of subscriptions of the webhook endpoint.",
+		[]string{"endpoint_id"},
+	)
+	webhooksCreatedDesc = newDesc(
+		webhooksSubsystem,
+		"created_timestamp",
+		"Unix timestamp when the webhook endpoint was created.",
+		[]string{"endpoint_id"},
+	)
+	webhooksLastModifiedDesc = newDesc(
+		webhooksSubsystem,
+		"last_modified_timestamp",
+		"Unix timestamp when the webhook endpoint was last modified.",
+		[]string{"endpoint_id"},
+	)
+)
+
+type TailscaleWebhooksCollector struct {
+	log *slog.Logger
+}
+
+func init() {
+	registerCollector(webhooksSubsystem, NewTailscaleWebhooksCollector)
+}
+
+// NewTailscaleWebhooksCollector creates the webhooks collector. It is only
+// enabled when configured, as it requires an additional OAuth scope.
+func NewTailscaleWebhooksCollector(config collectorConfig) (Collector, error) {
+	if !config.Webhooks {
+		return nil, nil
+	}
+
+	return &TailscaleWebhooksCollector{
+		log: config.logger,
+	}, nil
+}
+
+func (c TailscaleWebhooksCollector) Update(
+	ctx context.Context,
+	client TailscaleClient,
+	ch chan<- prometheus.Metric,
+) error {
+	c.log.DebugContext(ctx, "Collecting webhooks metrics")
+
+	webhooks, err := client.Webhooks().List(ctx)
+	if err != nil {
+		c.log.ErrorContext(
+			ctx,
+			"Error getting Tailscale webhooks; ensure the OAuth client has webhooks:read",
+			"error",
+			err.Error(),
+		)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		webhooksEndpointsDesc, prometheus.GaugeValue, float64(len(webhooks)),
+	)
+
+	for _, webhook := range webhooks {
+		ch <- prometheus.MustNewConstMetric(
+			webhooksInfoDesc, prometheus.GaugeValue, 1,
+			webhook.EndpointID,
+			webhookEndpointHost(webhook.EndpointURL),
+			webhookProviderType(webhook.ProviderType),
+		)
+
+		for _, subscription := range webhook.Subscriptions {
+			ch <- prometheus.MustNewConstMetric(
+				webhooksSubscriptionDesc, prometheus.GaugeValue, 1,
+				webhook.EndpointID, string(subscription),
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			webhooksSubscriptionsDesc, prometheus.GaugeValue, float64(len(webhook.Subscriptions)),
+			webhook.EndpointID,
+		)
+
+		if !webhook.Created.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				webhooksCreatedDesc, prometheus.GaugeValue, float64(webhook.Created.Unix()),
+				webhook.EndpointID,
+			)
+		}
+		if !webhook.LastModified.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				webhooksLastModifiedDesc, prometheus.GaugeValue, float64(webhook.LastModified.Unix()),
+				webhook.EndpointID,
+			)
+		}
+	}
+
+	return nil
+}
+
+func webhookProviderType(providerType tailscale.WebhookProviderType) string {
+	if providerType == tailscale.WebhookEmptyProviderType {
+		return defaultWebhookProviderType
+	}
+	return string(providerType)
+}
+
+// webhookEndpointHost returns the host of a webhook endpoint URL. Provider URLs
+// such as Slack's embed secrets in their path, so the full URL is never exported.
+func webhookEndpointHost(endpointURL string) string {
+	u, err := url.Parse(endpointURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
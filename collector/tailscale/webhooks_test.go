@@ -0,0 +1,89 @@
+package tailscale
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"tailscale.com/client/tailscale/v2"
+)
+
+func TestTailscaleWebhooksCollector_Update(t *testing.T) {
+	collector := &TailscaleWebhooksCollector{log: slog.Default()}
+	ch := make(chan prometheus.Metric, 16)
+
+	err := collector.Update(context.Background(), &MockTailscaleClient{
+		webhooksClient: &MockWebhooksClient{
+			webhooks: []tailscale.Webhook{
+				{
+					EndpointID:       "wh-123",
+					EndpointURL:      "https://hooks.slack.com/services/T000/B000/secret",
+					ProviderType:     tailscale.WebhookSlackProviderType,
+					CreatorLoginName: "admin@example.com",
+					Created:          time.Unix(1_700_000_000, 0),
+					LastModified:     time.Unix(1_710_000_000, 0),
+					Subscriptions: []tailscale.WebhookSubscriptionType{
+						tailscale.WebhookCategoryTailnetManagement,
+						tailscale.WebhookNodeKeyExpired,
+					},
+				},
+				{
+					EndpointID:  "wh-456",
+					EndpointURL: "https://siem.example.com/ingest",
+				},
+			},
+		},
+	}, ch)
+	close(ch)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var metrics []prometheus.Metric
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&TestMetricCollector{metrics: metrics})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP tailscale_webhooks_created_timestamp Unix timestamp when the webhook endpoint was created.
+# TYPE tailscale_webhooks_created_timestamp gauge
+tailscale_webhooks_created_timestamp{endpoint_id="wh-123"} 1.7e+09
+# HELP tailscale_webhooks_endpoints Number of configured webhook endpoints.
+# TYPE tailscale_webhooks_endpoints gauge
+tailscale_webhooks_endpoints 2
+# HELP tailscale_webhooks_info Webhook endpoint information. Only the host of the endpoint URL is exposed.
+# TYPE tailscale_webhooks_info gauge
+tailscale_webhooks_info{endpoint_host="siem.example.com",endpoint_id="wh-456",provider_type="generic"} 1
+tailscale_webhooks_info{endpoint_host="hooks.slack.com",endpoint_id="wh-123",provider_type="slack"} 1
+# HELP tailscale_webhooks_last_modified_timestamp Unix timestamp when the webhook endpoint was last modified.
+# TYPE tailscale_webhooks_last_modified_timestamp gauge
+tailscale_webhooks_last_modified_timestamp{endpoint_id="wh-123"} 1.71e+09
+# HELP tailscale_webhooks_subscription Event or event category the webhook endpoint is subscribed to.
+# TYPE tailscale_webhooks_subscription gauge
+tailscale_webhooks_subscription{endpoint_id="wh-123",subscription="categoryTailnetManagement"} 1
+tailscale_webhooks_subscription{endpoint_id="wh-123",subscription="nodeKeyExpired"} 1
+# HELP tailscale_webhooks_subscriptions Number of subscriptions of the webhook endpoint.
+# TYPE tailscale_webhooks_subscriptions gauge
+tailscale_webhooks_subscriptions{endpoint_id="wh-123"} 2
+tailscale_webhooks_subscriptions{endpoint_id="wh-456"} 0
+`)); err != nil {
+		t.Errorf("metrics mismatch: %v", err)
+	}
+}
+
+func TestNewTailscaleWebhooksCollector_Disabled(t *testing.T) {
+	coll, err := NewTailscaleWebhooksCollector(collectorConfig{logger: slog.Default()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coll != nil {
+		t.Errorf("expected webhooks collector to be disabled")
+	}
+}